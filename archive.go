@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// archiveColumns are the github_activity columns copied into activity_archive
+const archiveColumns = `id, date, repository, activity_type, count, url, created_at, github_id, number, title, state, message, private, stale`
+
+// Handler for POST /api/archive?months=N: moves activity older than N months
+// (default 12) out of github_activity into activity_archive and reports how
+// many rows were moved. N may not be shorter than the history window, or the
+// next refresh would fetch the archived rows again.
+func (app *App) archiveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	months := 12
+	if monthsStr := r.URL.Query().Get("months"); monthsStr != "" {
+		n, err := strconv.Atoi(monthsStr)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, "invalid months: "+monthsStr+", expected a positive integer")
+			return
+		}
+		months = n
+	}
+	if months < app.GitHubService.HistoryMonths {
+		writeJSONError(w, http.StatusBadRequest,
+			fmt.Sprintf("months must be at least the %d month history window", app.GitHubService.HistoryMonths))
+		return
+	}
+
+	before := time.Now().UTC().AddDate(0, -months, 0).Format("2006-01-02")
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO activity_archive (`+archiveColumns+`)
+		SELECT `+archiveColumns+` FROM github_activity WHERE date < ?
+	`, before)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res, err := tx.Exec("DELETE FROM github_activity WHERE date < ?", before)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	archived, err := res.RowsAffected()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"archived": archived,
+		"before":   before,
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireToken rejects requests without an "Authorization: Bearer <API_TOKEN>"
+// header. When no API token is configured every request is allowed.
+func (app *App) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.APIToken == "" {
+			next(w, r)
+			return
+		}
+
+		if !app.validAPIToken(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="recentrepos"`)
+			writeJSONError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// validAPIToken reports whether the request carries API_TOKEN as a bearer token
+func (app *App) validAPIToken(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && app.APIToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(app.APIToken)) == 1
+}
+
+// basicAuth puts every route, static files included, behind HTTP Basic Auth
+// when BASIC_AUTH_USER and BASIC_AUTH_PASS are set. Since a request has only
+// one Authorization header, one carrying a valid API_TOKEN is let through as
+// well, so scripts calling the refresh endpoints need just the token.
+func (app *App) basicAuth(next http.Handler) http.Handler {
+	user, pass := app.Config.BasicAuthUser, app.Config.BasicAuthPass
+	if user == "" || pass == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.validAPIToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gotUser, gotPass, ok := r.BasicAuth()
+		// Compare both parts every time so the response time doesn't reveal
+		// which one was wrong
+		userOK := subtle.ConstantTimeCompare([]byte(gotUser), []byte(user)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(gotPass), []byte(pass)) == 1
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="recentrepos", charset="UTF-8"`)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readAccess guards read-only endpoints, which stay open unless API_PROTECT_READS is set
+func (app *App) readAccess(next http.HandlerFunc) http.HandlerFunc {
+	if !app.ProtectReads {
+		return next
+	}
+	return app.requireToken(next)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuthRejectsWithJSONError(t *testing.T) {
+	app := &App{Config: &Config{BasicAuthUser: "octo", BasicAuthPass: "secret"}}
+	handler := app.basicAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/activity", nil)
+	req.SetBasicAuth("octo", "wrong")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got == "" {
+		t.Error("missing WWW-Authenticate header")
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != http.StatusUnauthorized {
+		t.Errorf("got body %+v (%v), want a JSON error with code %d", body, err, http.StatusUnauthorized)
+	}
+
+	req.SetBasicAuth("octo", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("with valid credentials got status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
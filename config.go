@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting, read once at startup from the environment and
+// optionally a JSON file named by CONFIG_FILE. The file is an object keyed by
+// the environment variable names, with string values; a variable set in the
+// environment wins over the file.
+type Config struct {
+	Port     string
+	BindAddr string
+	LogLevel string
+
+	// APIToken guards refreshes, and reads too when ProtectReads is set
+	APIToken     string
+	ProtectReads bool
+	// BasicAuthUser and BasicAuthPass, when both set, put the whole site
+	// behind HTTP Basic Auth
+	BasicAuthUser string
+	BasicAuthPass string
+
+	GitHubToken string
+	Username    string
+	APIBase     string
+	WebBase     string
+	UserAgent   string
+	Orgs        []string
+	// CommitAuthors are the logins and emails whose commits are collected;
+	// empty means just the username
+	CommitAuthors []string
+	RepoInclude   []string
+	RepoExclude   []string
+	IncludeForks  bool
+	MaxRepos      int
+	// IncludePrivate keeps private repositories; defaults to true
+	IncludePrivate bool
+	UseGraphQL     bool
+
+	HistoryMonths  int
+	SampleDataPath string
+	// RefreshInterval schedules a refresh in the background every interval
+	// while the server runs; 0 disables it
+	RefreshInterval time.Duration
+	// Location is the time zone (TIMEZONE) activity is grouped into days in;
+	// defaults to UTC
+	Location *time.Location
+
+	MaxRateLimitWait          time.Duration
+	MaxSecondaryRateLimitWait time.Duration
+	MaxRetries                int
+
+	// DefaultView is the activity type the index page's activity list shows
+	// initially, or "all"
+	DefaultView string
+
+	// CacheDir enables the on-disk response cache when set
+	CacheDir string
+	CacheTTL time.Duration
+}
+
+// configSource looks settings up in the environment, then the config file
+type configSource map[string]string
+
+func (s configSource) get(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return s[key]
+}
+
+// displayUsername names whose activity is shown: GITHUB_USERNAME, or in
+// sample mode without one, the owner of the sample dataset
+func (c *Config) displayUsername() string {
+	if c.Username == "" {
+		return sampleUsername
+	}
+	return c.Username
+}
+
+// loadConfig reads the configuration, applying defaults for unset values and
+// logging (then ignoring) invalid ones. Only an unreadable config file, or a
+// GITHUB_TOKEN without a GITHUB_USERNAME, is an error.
+func loadConfig() (*Config, error) {
+	source := configSource{}
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := json.Unmarshal(data, &source); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", file, err)
+		}
+	}
+
+	cfg := &Config{
+		Port:           source.get("PORT"),
+		BindAddr:       source.get("BIND_ADDR"),
+		LogLevel:       source.get("LOG_LEVEL"),
+		APIToken:       source.get("API_TOKEN"),
+		BasicAuthUser:  source.get("BASIC_AUTH_USER"),
+		BasicAuthPass:  source.get("BASIC_AUTH_PASS"),
+		GitHubToken:    source.get("GITHUB_TOKEN"),
+		Username:       source.get("GITHUB_USERNAME"),
+		APIBase:        baseURL(source.get("GITHUB_API_BASE"), "https://api.github.com"),
+		WebBase:        baseURL(source.get("GITHUB_WEB_BASE"), "https://github.com"),
+		Orgs:           splitList(source.get("GITHUB_ORGS")),
+		CommitAuthors:  splitList(source.get("GITHUB_COMMIT_AUTHORS")),
+		RepoInclude:    repoPatterns("GITHUB_REPO_INCLUDE", source.get("GITHUB_REPO_INCLUDE")),
+		RepoExclude:    repoPatterns("GITHUB_REPO_EXCLUDE", source.get("GITHUB_REPO_EXCLUDE")),
+		UseGraphQL:     strings.EqualFold(source.get("GITHUB_API"), "graphql"),
+		SampleDataPath: source.get("SAMPLE_DATA_PATH"),
+		CacheDir:       source.get("GITHUB_CACHE_DIR"),
+	}
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	// Only sample mode, which fetches nothing, may run without a username
+	if cfg.Username == "" && cfg.GitHubToken != "" {
+		return nil, errors.New("GITHUB_USERNAME is not set; set it in the environment or in CONFIG_FILE to choose whose activity is fetched")
+	}
+	if (cfg.BasicAuthUser == "") != (cfg.BasicAuthPass == "") {
+		slog.Warn("basic auth needs both BASIC_AUTH_USER and BASIC_AUTH_PASS, leaving the site open")
+	}
+	cfg.UserAgent = strings.TrimSpace(source.get("GITHUB_USER_AGENT"))
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "RecentRepos/" + version
+	}
+	cfg.DefaultView = "all"
+	if v := source.get("DEFAULT_VIEW"); v != "" {
+		if v == "all" || validActivityType(v) {
+			cfg.DefaultView = v
+		} else {
+			slog.Warn("invalid DEFAULT_VIEW, using default", "value", v, "default", cfg.DefaultView)
+		}
+	}
+
+	cfg.Location = time.UTC
+	if v := source.get("TIMEZONE"); v != "" {
+		if loc, err := time.LoadLocation(v); err == nil {
+			cfg.Location = loc
+		} else {
+			slog.Warn("invalid TIMEZONE, using UTC", "value", v, "error", err)
+		}
+	}
+
+	cfg.IncludePrivate = true
+	if v := source.get("GITHUB_INCLUDE_PRIVATE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.IncludePrivate = b
+		} else {
+			slog.Warn("invalid GITHUB_INCLUDE_PRIVATE, using default", "value", v, "default", cfg.IncludePrivate)
+		}
+	}
+
+	cfg.ProtectReads, _ = strconv.ParseBool(source.get("API_PROTECT_READS"))
+	cfg.IncludeForks, _ = strconv.ParseBool(source.get("GITHUB_INCLUDE_FORKS"))
+
+	cfg.MaxRateLimitWait = 5 * time.Minute
+	if v := source.get("GITHUB_MAX_RATE_LIMIT_WAIT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.MaxRateLimitWait = d
+		}
+	}
+
+	cfg.MaxSecondaryRateLimitWait = 2 * time.Minute
+	if v := source.get("GITHUB_MAX_SECONDARY_RATE_LIMIT_WAIT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.MaxSecondaryRateLimitWait = d
+		}
+	}
+
+	cfg.MaxRetries = 3
+	if v := source.get("GITHUB_MAX_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxRetries = n
+		}
+	}
+
+	if v := source.get("GITHUB_MAX_REPOS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxRepos = n
+		} else {
+			slog.Warn("invalid GITHUB_MAX_REPOS, ignoring", "value", v)
+		}
+	}
+
+	cfg.HistoryMonths = 6
+	if v := source.get("HISTORY_MONTHS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.HistoryMonths = n
+		} else {
+			slog.Warn("invalid HISTORY_MONTHS, using default", "value", v, "default", cfg.HistoryMonths)
+		}
+	}
+
+	if v := source.get("REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.RefreshInterval = d
+		} else {
+			slog.Warn("invalid REFRESH_INTERVAL, scheduled refreshes disabled", "value", v)
+		}
+	}
+
+	cfg.CacheTTL = 15 * time.Minute
+	if v := source.get("GITHUB_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.CacheTTL = d
+		} else {
+			slog.Warn("invalid GITHUB_CACHE_TTL, using default", "value", v, "default", cfg.CacheTTL)
+		}
+	}
+
+	return cfg, nil
+}
+
+// baseURL trims trailing slashes from a base URL setting, falling back to def
+// when unset
+func baseURL(value, def string) string {
+	if v := strings.TrimRight(strings.TrimSpace(value), "/"); v != "" {
+		return v
+	}
+	return def
+}
+
+// splitList splits a comma-separated setting, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// repoPatterns parses the comma-separated glob patterns of the named setting,
+// dropping (and logging) malformed ones
+func repoPatterns(name, value string) []string {
+	var patterns []string
+	for _, pattern := range splitList(value) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			slog.Warn("ignoring invalid repository pattern", "variable", name, "pattern", pattern, "error", err)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadConfigUsername(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("GITHUB_USERNAME", "")
+
+	t.Setenv("GITHUB_TOKEN", "token")
+	if _, err := loadConfig(); err == nil {
+		t.Error("a GITHUB_TOKEN without GITHUB_USERNAME should be an error")
+	}
+
+	// Sample mode runs without a username and isn't given a made-up one
+	t.Setenv("GITHUB_TOKEN", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "" {
+		t.Errorf("got username %q in sample mode, want it left unset", cfg.Username)
+	}
+	if got := cfg.displayUsername(); got != sampleUsername {
+		t.Errorf("got display username %q, want the sample owner %q", got, sampleUsername)
+	}
+
+	app := newTestApp(t)
+	app.Config = cfg
+	rec := httptest.NewRecorder()
+	app.statusHandler(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	var status struct {
+		GitHubUsername *string `json:"github_username"`
+		SampleOwner    string  `json:"sample_owner"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.GitHubUsername != nil || status.SampleOwner != sampleUsername {
+		t.Errorf("got github_username %v and sample_owner %q, want null and %q", status.GitHubUsername, status.SampleOwner, sampleUsername)
+	}
+}
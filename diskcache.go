@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// diskCache stores raw GitHub responses as files keyed by request URL, so
+// restarting the server during development doesn't spend the rate limit again.
+// Unlike ResponseCache, a fresh entry is served without contacting GitHub at
+// all. Its methods are safe to call on a nil *diskCache, which disables it.
+type diskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// newDiskCache returns a cache in dir whose entries live for ttl, or nil when
+// no directory is configured
+func newDiskCache(dir string, ttl time.Duration) *diskCache {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		slog.Warn("failed to create GitHub cache directory, disk cache disabled", "dir", dir, "error", err)
+		return nil
+	}
+	return &diskCache{dir: dir, ttl: ttl}
+}
+
+// path names the file holding the response for url
+func (c *diskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the stored response for url if it is younger than the TTL
+func (c *diskCache) get(ctx context.Context, url string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	path := c.path(url)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > c.ttl {
+		return nil, false
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	slog.InfoContext(ctx, "serving GitHub response from disk cache", "url", url)
+	return body, true
+}
+
+// put stores a successful response for url, logging rather than failing the
+// request when it can't be written
+func (c *diskCache) put(ctx context.Context, url string, body []byte) {
+	if c == nil {
+		return
+	}
+
+	// Write to a temporary file first so a concurrent get never sees a
+	// partial response
+	tmp, err := os.CreateTemp(c.dir, "partial-*")
+	if err != nil {
+		slog.WarnContext(ctx, "failed to write GitHub disk cache", "url", url, "error", err)
+		return
+	}
+	_, err = tmp.Write(body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(url))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.WarnContext(ctx, "failed to write GitHub disk cache", "url", url, "error", err)
+	}
+}
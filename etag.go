@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// activityETag returns a weak ETag for a response built from github_activity.
+// It covers the newest row id and the row count, which change when rows are
+// added or removed, the last time stored activity was upserted (by a full or
+// single-repository refresh), since that also updates existing rows, the
+// current day, which moves default date windows, and the query string.
+func (app *App) activityETag(r *http.Request) (string, error) {
+	var maxID, count int
+	if err := app.DB.QueryRow("SELECT COALESCE(MAX(id), 0), COUNT(*) FROM github_activity").Scan(&maxID, &count); err != nil {
+		return "", err
+	}
+	updated, _, err := app.getMetadata("activity_updated_at")
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%s|%s|%s",
+		maxID, count, updated, app.GitHubService.Today().Format("2006-01-02"), r.URL.RawQuery)))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`, nil
+}
+
+// notModified sets the ETag header for an activity response and, when the
+// request's If-None-Match already names it, answers 304 Not Modified and
+// returns true. If the ETag can't be computed the response is served as usual.
+func (app *App) notModified(w http.ResponseWriter, r *http.Request) bool {
+	etag, err := app.activityETag(r)
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to compute ETag", "error", err)
+		return false
+	}
+	w.Header().Set("ETag", etag)
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		// If-None-Match uses weak comparison
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
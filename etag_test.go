@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSingleRepoRefreshChangesActivityETag(t *testing.T) {
+	app := newTestApp(t)
+
+	// Store the repository's sample activity (served as there is no token),
+	// with one detail out of date so the refresh updates it in place and
+	// leaves the row count and ids unchanged
+	fetched, err := app.GitHubService.sampleRepoActivity("kristofer/RecentRepos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range fetched.Activities {
+		fetched.Activities[i].Title = "Outdated title"
+	}
+	app.store(t, fetched)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/activity", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		app.getActivityHandler(rec, req)
+		return rec
+	}
+	etag := get("").Header().Get("ETag")
+	if rec := get(etag); rec.Code != http.StatusNotModified {
+		t.Fatalf("got status %d for an unchanged ETag, want 304", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	app.refreshRepoHandler(rec, httptest.NewRequest(http.MethodPost, "/api/refresh/repo?name=kristofer/RecentRepos", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh got status %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := get(etag); rec.Code != http.StatusOK {
+		t.Errorf("got status %d after the refresh, want 200 with the updated activity", rec.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Handler for /api/export.csv: streams every stored activity as CSV, oldest first
+func (app *App) exportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := app.DB.Query(`
+		SELECT date, repository, activity_type, count, COALESCE(url, '') as url
+		FROM github_activity
+		ORDER BY date, id
+	`)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="github-activity.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "repository", "activity_type", "count", "url"})
+
+	for rows.Next() {
+		var date, repository, activityType, url string
+		var count int
+		if err := rows.Scan(&date, &repository, &activityType, &count, &url); err != nil {
+			// Headers are already sent, so the best we can do is stop and log
+			slog.ErrorContext(r.Context(), "failed to export activity", "error", err)
+			break
+		}
+		if err := cw.Write([]string{date, repository, activityType, strconv.Itoa(count), url}); err != nil {
+			slog.WarnContext(r.Context(), "failed to write CSV export", "error", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "failed to export activity", "error", err)
+	}
+
+	cw.Flush()
+}
+
+// ndjsonFlushEvery is how many rows /api/export.ndjson writes between flushes
+const ndjsonFlushEvery = 100
+
+// Handler for /api/export.ndjson: streams every stored activity matching the
+// optional type and repository filters as newline-delimited JSON, oldest first,
+// flushing as it goes so large exports aren't buffered
+func (app *App) exportNDJSONHandler(w http.ResponseWriter, r *http.Request) {
+	where, args, err := activityFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := app.DB.Query(`
+		SELECT id, date, repository, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, state, message, private, stale
+		FROM github_activity
+		`+where+`
+		ORDER BY date, id
+	`, args...)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="github-activity.ndjson"`)
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for n := 1; rows.Next(); n++ {
+		var activity GitHubActivity
+		var dateStr string
+		err := rows.Scan(&activity.ID, &dateStr, &activity.Repository, &activity.ActivityType, &activity.Count,
+			&activity.URL, &activity.GitHubID, &activity.Number, &activity.Title, &activity.State, &activity.Message, &activity.Private, &activity.Stale)
+		if err != nil {
+			// Headers are already sent, so the best we can do is stop and log
+			slog.ErrorContext(r.Context(), "failed to export activity", "error", err)
+			return
+		}
+		activity.Date = app.parseActivityDate(dateStr)
+		if err := enc.Encode(activity); err != nil {
+			slog.WarnContext(r.Context(), "failed to write NDJSON export", "error", err)
+			return
+		}
+		if flusher != nil && n%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "failed to export activity", "error", err)
+	}
+}
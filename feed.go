@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedItem is a single activity rendered into a syndication feed
+type FeedItem struct {
+	ID         string
+	Title      string
+	URL        string
+	Date       time.Time
+	Repository string
+	Type       string
+	Content    string
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Link     atomLink     `xml:"link"`
+	Category atomCategory `xml:"category"`
+	Content  string       `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// jsonFeed is a JSON Feed 1.1 document (https://jsonfeed.org/version/1.1)
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentText   string   `json:"content_text"`
+	DatePublished string   `json:"date_published"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// recentFeedItems returns the most recent commits, pull requests and issues as feed items
+func (app *App) recentFeedItems(limit int) ([]FeedItem, error) {
+	rows, err := app.DB.Query(`
+		SELECT date, repository, activity_type, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, message
+		FROM github_activity
+		WHERE activity_type IN ('commit', 'pull_request', 'issue')
+		ORDER BY date DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []FeedItem
+	for rows.Next() {
+		var activity GitHubActivity
+		var dateStr string
+		err := rows.Scan(&dateStr, &activity.Repository, &activity.ActivityType, &activity.URL, &activity.GitHubID,
+			&activity.Number, &activity.Title, &activity.Message)
+		if err != nil {
+			return nil, err
+		}
+		activity.Date = app.parseActivityDate(dateStr)
+		items = append(items, newFeedItem(activity, app.GitHubService.WebBase))
+	}
+
+	return items, rows.Err()
+}
+
+// Handler for /api/feed: returns commits, pull requests and issues from every
+// repository as a single list, newest first, paginated with page and limit.
+// Each item carries its activity_type and the fields specific to that type.
+func (app *App) getFeedHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var total int
+	err = app.DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM github_activity
+		WHERE activity_type IN ('commit', 'pull_request', 'issue')
+	`).Scan(&total)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rows, err := app.DB.Query(`
+		SELECT id, date, repository, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, state, message, private, stale
+		FROM github_activity
+		WHERE activity_type IN ('commit', 'pull_request', 'issue')
+		ORDER BY date DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, limit, (page-1)*limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	items := []GitHubActivity{}
+	for rows.Next() {
+		var activity GitHubActivity
+		var dateStr string
+		err := rows.Scan(&activity.ID, &dateStr, &activity.Repository, &activity.ActivityType, &activity.Count,
+			&activity.URL, &activity.GitHubID, &activity.Number, &activity.Title, &activity.State, &activity.Message, &activity.Private, &activity.Stale)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		activity.Date = app.parseActivityDate(dateStr)
+		items = append(items, activity)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	_, end, pagination := paginate(total, page, limit)
+	setPageLinks(w, r, page, end < total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":       items,
+		"pagination": pagination,
+	})
+}
+
+// newFeedItem describes an activity for display in a feed reader, linking to
+// the repository under webBase when the activity has no URL of its own
+func newFeedItem(activity GitHubActivity, webBase string) FeedItem {
+	item := FeedItem{
+		ID:         fmt.Sprintf("tag:github.com,2008:%s/%s/%s", activity.Repository, activity.ActivityType, activity.GitHubID),
+		URL:        activity.URL,
+		Date:       activity.Date,
+		Repository: activity.Repository,
+		Type:       activity.ActivityType,
+	}
+	if item.URL == "" {
+		item.URL = webBase + "/" + activity.Repository
+	}
+
+	switch activity.ActivityType {
+	case "commit":
+		summary, _, _ := strings.Cut(activity.Message, "\n")
+		if summary == "" {
+			summary = "Commit " + activity.GitHubID
+		}
+		item.Title = fmt.Sprintf("%s: %s", activity.Repository, summary)
+		item.Content = activity.Message
+	case "pull_request":
+		item.Title = fmt.Sprintf("%s: Pull request #%d %s", activity.Repository, activity.Number, activity.Title)
+		item.Content = fmt.Sprintf("Opened pull request #%d in %s: %s", activity.Number, activity.Repository, activity.Title)
+	case "issue":
+		item.Title = fmt.Sprintf("%s: Issue #%d %s", activity.Repository, activity.Number, activity.Title)
+		item.Content = fmt.Sprintf("Opened issue #%d in %s: %s", activity.Number, activity.Repository, activity.Title)
+	}
+
+	return item
+}
+
+// feedLimit reads the limit query parameter, defaulting to 50 entries and capped at 500
+func feedLimit(r *http.Request) int {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	return limit
+}
+
+// requestBaseURL returns the scheme and host the request was made to
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// Handler for /feed.atom: renders recent activity as an Atom feed
+func (app *App) atomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := app.recentFeedItems(feedLimit(r))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	username := app.Config.displayUsername()
+
+	baseURL := requestBaseURL(r)
+	feed := atomFeed{
+		ID:      baseURL + "/feed.atom",
+		Title:   fmt.Sprintf("Recent GitHub activity for %s", username),
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: username},
+		Links: []atomLink{
+			{Href: baseURL + "/feed.atom", Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL + "/", Rel: "alternate", Type: "text/html"},
+		},
+	}
+	if len(items) > 0 {
+		feed.Updated = items[0].Date.UTC().Format(time.RFC3339)
+	}
+
+	for _, item := range items {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:       item.ID,
+			Title:    item.Title,
+			Updated:  item.Date.UTC().Format(time.RFC3339),
+			Link:     atomLink{Href: item.URL, Rel: "alternate"},
+			Category: atomCategory{Term: item.Type},
+			Content:  item.Content,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
+
+// Handler for /feed.json: renders recent activity as a JSON Feed 1.1 document
+func (app *App) jsonFeedHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := app.recentFeedItems(feedLimit(r))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	username := app.Config.displayUsername()
+	baseURL := requestBaseURL(r)
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       fmt.Sprintf("Recent GitHub activity for %s", username),
+		HomePageURL: baseURL + "/",
+		FeedURL:     baseURL + "/feed.json",
+		Authors:     []jsonFeedAuthor{{Name: username}},
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, item := range items {
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentText:   item.Content,
+			DatePublished: item.Date.UTC().Format(time.RFC3339),
+			Tags:          []string{item.Type, item.Repository},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	json.NewEncoder(w).Encode(feed)
+}
@@ -1,15 +1,156 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type GitHubService struct {
 	Token string
+	// APIBase is the root of the REST API, such as
+	// https://github.example.com/api/v3 for GitHub Enterprise
+	APIBase string
+	// WebBase is the root of the web UI, used to build links to repositories
+	// and commits
+	WebBase string
+	// UserAgent is sent with every request, as GitHub asks clients to identify
+	// themselves
+	UserAgent string
+	// Orgs lists organizations whose repositories are fetched alongside the user's
+	Orgs []string
+	// CommitAuthors lists the logins and emails whose commits count as the
+	// user's, for commits made under secondary identities; empty means just
+	// the username being refreshed
+	CommitAuthors []string
+	// RepoInclude and RepoExclude are glob patterns selecting which repositories
+	// are fetched. Patterns containing a slash match the full owner/name,
+	// others just the name. Exclude wins over include; no include patterns
+	// means every repository is included.
+	RepoInclude []string
+	RepoExclude []string
+	// IncludeForks walks forked repositories too; by default they are skipped
+	IncludeForks bool
+	// MaxRepos caps how many repositories a refresh walks, most recently
+	// pushed first; 0 means no cap
+	MaxRepos int
+	// IncludePrivate walks private repositories and keeps activity in them
+	IncludePrivate bool
+	// Cache stores ETags and payloads for conditional requests; nil disables it
+	Cache ResponseCache
+	// DiskCache serves recent responses without any request (GITHUB_CACHE_DIR);
+	// nil disables it
+	DiskCache *diskCache
+	// MaxRateLimitWait caps how long a request will sleep waiting for the
+	// rate limit to reset before giving up with a RateLimitError
+	MaxRateLimitWait time.Duration
+	// MaxSecondaryRateLimitWait caps the total time a single request spends
+	// honoring Retry-After from GitHub's secondary rate limit
+	MaxSecondaryRateLimitWait time.Duration
+	// MaxRetries is how many times a request failing with a network error, a
+	// 5xx response or an exhausted rate limit is retried before giving up
+	MaxRetries int
+	// HistoryMonths is how many months of activity to fetch and display
+	HistoryMonths int
+	// Location is the time zone activity is grouped into days in, so it falls
+	// on the author's local day; nil means UTC. Dates are stored in UTC.
+	Location *time.Location
+	// UseGraphQL fetches activity through the GraphQL API (GITHUB_API=graphql)
+	// instead of walking every repository over REST
+	UseGraphQL bool
+	// SampleDataPath points to a JSON file of activities served instead of the
+	// built-in sample data when no token is configured
+	SampleDataPath string
+	// ObserveRequest, if set, is called with the status code of every GitHub
+	// response, or "error" when no response was received
+	ObserveRequest func(code string)
+	// Transport sends the requests; nil means http.DefaultTransport
+	Transport http.RoundTripper
+
+	mu sync.Mutex
+	// rateLimits tracks each rate limit resource ("core", "search", "graphql")
+	// separately, since they have independent budgets
+	rateLimits map[string]rateLimitState
+	// rateLimitStatus caches the last /rate_limit answer, fetched at
+	// rateLimitStatusAt
+	rateLimitStatus   RateLimitStatus
+	rateLimitStatusAt time.Time
+}
+
+// rateLimitState is the last reported budget of one rate limit resource
+type rateLimitState struct {
+	remaining int
+	reset     time.Time
+}
+
+// ResponseCache persists GitHub responses keyed by request URL so conditional
+// requests can reuse them across restarts
+type ResponseCache interface {
+	Get(key string) (etag string, body []byte, found bool)
+	Put(key, etag string, body []byte) error
+}
+
+// APIError reports an unexpected HTTP status returned by the GitHub API
+type APIError struct {
+	StatusCode int
+	Repo       string
+}
+
+func (e *APIError) Error() string {
+	if e.Repo != "" {
+		return fmt.Sprintf("GitHub API returned status: %d for repo %s", e.StatusCode, e.Repo)
+	}
+	return fmt.Sprintf("GitHub API returned status: %d", e.StatusCode)
+}
+
+// logFetchWarning logs a failed per-repository fetch with the time spent and,
+// for unexpected API responses, the status code GitHub returned
+func logFetchWarning(ctx context.Context, msg, repo string, start time.Time, err error) {
+	attrs := []any{"repo", repo, "duration", time.Since(start), "error", err}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		attrs = append(attrs, "status_code", apiErr.StatusCode)
+	}
+	slog.WarnContext(ctx, msg, attrs...)
+}
+
+// RateLimitError is returned when the GitHub rate limit is exhausted and the
+// reset is further away than GitHubService.MaxRateLimitWait, or requests keep
+// being rejected after MaxRetries attempts. Reset is zero if GitHub didn't say.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return "GitHub rate limit exceeded"
+	}
+	return fmt.Sprintf("GitHub rate limit exceeded, resets at %s", e.Reset.Format(time.RFC3339))
+}
+
+// SecondaryRateLimitError is returned when GitHub's secondary (abuse detection)
+// rate limit keeps rejecting a request for longer than
+// GitHubService.MaxSecondaryRateLimitWait in total
+type SecondaryRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SecondaryRateLimitError) Error() string {
+	return fmt.Sprintf("GitHub secondary rate limit exceeded, retry after %s", e.RetryAfter)
 }
 
 type GitHubEvent struct {
@@ -17,14 +158,55 @@ type GitHubEvent struct {
 	Type      string                 `json:"type"`
 	Repo      GitHubRepo             `json:"repo"`
 	CreatedAt time.Time              `json:"created_at"`
+	Public    bool                   `json:"public"`
 	Payload   map[string]interface{} `json:"payload"`
 }
 
 type GitHubRepo struct {
-	Name     string `json:"name"`
-	FullName string `json:"full_name"`
-	URL      string `json:"url"`
-	HTMLURL  string `json:"html_url"`
+	Name            string `json:"name"`
+	FullName        string `json:"full_name"`
+	URL             string `json:"url"`
+	HTMLURL         string `json:"html_url"`
+	StargazersCount int    `json:"stargazers_count"`
+	ForksCount      int    `json:"forks_count"`
+	Fork            bool   `json:"fork"`
+	Private         bool   `json:"private"`
+	// Language is the repository's primary language, empty if GitHub could
+	// not detect one
+	Language    string `json:"language"`
+	Description string `json:"description"`
+	// Topics are returned by current API versions without the old
+	// mercy-preview media type
+	Topics   []string  `json:"topics"`
+	PushedAt time.Time `json:"pushed_at"`
+}
+
+// ProgressFunc receives human-readable progress messages during a refresh
+type ProgressFunc func(message string)
+
+// report sends a formatted progress message if a callback was provided
+func (p ProgressFunc) report(format string, args ...interface{}) {
+	if p != nil {
+		p(fmt.Sprintf(format, args...))
+	}
+}
+
+// UserActivity is the result of a refresh: the user's activity along with the
+// repositories it was collected from
+type UserActivity struct {
+	Activities []GitHubActivity
+	Repos      []GitHubRepo
+	// Since is the start of the history window the activity was fetched over
+	Since time.Time
+	// DailyCommits is set when commits are counted per repository and day
+	// (GraphQL) rather than listed one row per SHA (REST)
+	DailyCommits bool
+	// CommitWatermarks holds, for each repository whose commits were listed,
+	// the date of the newest commit returned
+	CommitWatermarks map[string]time.Time
+	// GoneRepos were listed but answered 404 when their commits were fetched,
+	// having been deleted or renamed in between
+	GoneRepos []string
 }
 
 type GitHubCommit struct {
@@ -34,8 +216,8 @@ type GitHubCommit struct {
 }
 
 type GitHubCommitData struct {
-	Message string                `json:"message"`
-	Author  GitHubCommitAuthor    `json:"author"`
+	Message string             `json:"message"`
+	Author  GitHubCommitAuthor `json:"author"`
 }
 
 type GitHubCommitAuthor struct {
@@ -44,12 +226,25 @@ type GitHubCommitAuthor struct {
 }
 
 type GitHubPullRequest struct {
-	Number    int       `json:"number"`
-	Title     string    `json:"title"`
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
 	User      GitHubUser `json:"user"`
-	HTMLURL   string    `json:"html_url"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	HTMLURL   string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	MergedAt  *time.Time `json:"merged_at"`
+}
+
+type GitHubIssue struct {
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	User      GitHubUser `json:"user"`
+	HTMLURL   string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	// PullRequest is set when the issues API returns a pull request
+	PullRequest *struct{} `json:"pull_request"`
 }
 
 type GitHubIssueComment struct {
@@ -65,52 +260,784 @@ type GitHubUser struct {
 }
 
 type GitHubPRReviewComment struct {
-	ID        int        `json:"id"`
-	User      GitHubUser `json:"user"`
-	Body      string     `json:"body"`
-	CreatedAt time.Time  `json:"created_at"`
-	HTMLURL   string     `json:"html_url"`
-	PullRequestURL string `json:"pull_request_url"`
+	ID             int        `json:"id"`
+	User           GitHubUser `json:"user"`
+	Body           string     `json:"body"`
+	CreatedAt      time.Time  `json:"created_at"`
+	HTMLURL        string     `json:"html_url"`
+	PullRequestURL string     `json:"pull_request_url"`
+}
+
+// NewGitHubService configures a GitHub client from the loaded configuration
+func NewGitHubService(cfg *Config) *GitHubService {
+	return &GitHubService{
+		Token:                     cfg.GitHubToken,
+		APIBase:                   cfg.APIBase,
+		WebBase:                   cfg.WebBase,
+		UserAgent:                 cfg.UserAgent,
+		Orgs:                      cfg.Orgs,
+		CommitAuthors:             cfg.CommitAuthors,
+		RepoInclude:               cfg.RepoInclude,
+		RepoExclude:               cfg.RepoExclude,
+		IncludeForks:              cfg.IncludeForks,
+		MaxRepos:                  cfg.MaxRepos,
+		IncludePrivate:            cfg.IncludePrivate,
+		MaxRateLimitWait:          cfg.MaxRateLimitWait,
+		MaxSecondaryRateLimitWait: cfg.MaxSecondaryRateLimitWait,
+		MaxRetries:                cfg.MaxRetries,
+		HistoryMonths:             cfg.HistoryMonths,
+		Location:                  cfg.Location,
+		UseGraphQL:                cfg.UseGraphQL,
+		SampleDataPath:            cfg.SampleDataPath,
+		DiskCache:                 newDiskCache(cfg.CacheDir, cfg.CacheTTL),
+	}
+}
+
+// graphQLURL is the GraphQL endpoint matching APIBase. GitHub Enterprise serves
+// REST under /api/v3 and GraphQL under /api/graphql.
+func (g *GitHubService) graphQLURL() string {
+	return strings.TrimSuffix(g.APIBase, "/v3") + "/graphql"
+}
+
+// matchRepoPattern reports whether a repository matches any of the patterns
+func matchRepoPattern(patterns []string, fullName string) bool {
+	_, name, _ := strings.Cut(fullName, "/")
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = fullName
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// repoAllowed applies RepoInclude and RepoExclude to a repository
+func (g *GitHubService) repoAllowed(fullName string) bool {
+	if matchRepoPattern(g.RepoExclude, fullName) {
+		return false
+	}
+	return len(g.RepoInclude) == 0 || matchRepoPattern(g.RepoInclude, fullName)
+}
+
+// filterRepos drops forks (unless IncludeForks is set) and repositories
+// rejected by RepoInclude and RepoExclude
+func (g *GitHubService) filterRepos(repos []GitHubRepo) []GitHubRepo {
+	var filtered []GitHubRepo
+	for _, repo := range repos {
+		if repo.Fork && !g.IncludeForks {
+			continue
+		}
+		if repo.Private && !g.IncludePrivate {
+			continue
+		}
+		if g.repoAllowed(repo.FullName) {
+			filtered = append(filtered, repo)
+		}
+	}
+	return filtered
+}
+
+// capRepos keeps the MaxRepos most recently pushed repositories, if MaxRepos
+// is set. The slice passed in is left untouched.
+func (g *GitHubService) capRepos(ctx context.Context, repos []GitHubRepo) []GitHubRepo {
+	if g.MaxRepos <= 0 || len(repos) <= g.MaxRepos {
+		return repos
+	}
+	repos = append([]GitHubRepo(nil), repos...)
+	sort.SliceStable(repos, func(i, j int) bool {
+		return repos[i].PushedAt.After(repos[j].PushedAt)
+	})
+	slog.InfoContext(ctx, "skipping least recently pushed repositories", "skipped", len(repos)-g.MaxRepos, "max_repos", g.MaxRepos)
+	return repos[:g.MaxRepos]
+}
+
+// HistoryStart returns the beginning of the configured history window
+func (g *GitHubService) HistoryStart() time.Time {
+	return time.Now().AddDate(0, -g.HistoryMonths, 0)
+}
+
+// location returns the configured time zone, defaulting to UTC
+func (g *GitHubService) location() *time.Location {
+	if g.Location == nil {
+		return time.UTC
+	}
+	return g.Location
+}
+
+// Today returns midnight of the current day in the configured time zone
+func (g *GitHubService) Today() time.Time {
+	now := time.Now().In(g.location())
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// ValidateToken checks the configured token by fetching the authenticated user.
+// It returns the login the token belongs to and the remaining core rate limit
+// (-1 if GitHub did not report it). A rejected token yields an APIError with
+// status 401.
+func (g *GitHubService) ValidateToken(ctx context.Context) (string, int, error) {
+	req, err := g.newRequest(ctx, g.APIBase+"/user")
+	if err != nil {
+		return "", -1, err
+	}
+	resp, err := g.doRequest(req)
+	if err != nil {
+		return "", -1, err
+	}
+	defer resp.Body.Close()
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		remaining = -1
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", remaining, &APIError{StatusCode: resp.StatusCode}
+	}
+
+	var user GitHubUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", remaining, err
+	}
+	return user.Login, remaining, nil
+}
+
+// RateLimitStatus is the core REST API budget reported by /rate_limit
+type RateLimitStatus struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// rateLimitStatusTTL is how long a fetched RateLimitStatus is reused
+const rateLimitStatusTTL = 30 * time.Second
+
+// RateLimitStatus returns the core rate limit budget from /rate_limit, reusing
+// the last answer for rateLimitStatusTTL. GitHub doesn't count these calls
+// against the limit, so they bypass the rate limit wait in doRequest, which
+// would otherwise stall the caller once the budget is spent.
+func (g *GitHubService) RateLimitStatus(ctx context.Context) (RateLimitStatus, error) {
+	g.mu.Lock()
+	if !g.rateLimitStatusAt.IsZero() && time.Since(g.rateLimitStatusAt) < rateLimitStatusTTL {
+		status := g.rateLimitStatus
+		g.mu.Unlock()
+		return status, nil
+	}
+	g.mu.Unlock()
+
+	req, err := g.newRequest(ctx, g.APIBase+"/rate_limit")
+	if err != nil {
+		return RateLimitStatus{}, err
+	}
+	if g.UserAgent != "" {
+		req.Header.Set("User-Agent", g.UserAgent)
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second, Transport: g.Transport}).Do(req)
+	g.observeRequest(resp, err)
+	if err != nil {
+		return RateLimitStatus{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return RateLimitStatus{}, &APIError{StatusCode: resp.StatusCode}
+	}
+
+	var body struct {
+		Resources struct {
+			Core struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return RateLimitStatus{}, err
+	}
+	core := body.Resources.Core
+	status := RateLimitStatus{Limit: core.Limit, Remaining: core.Remaining, Reset: time.Unix(core.Reset, 0).UTC()}
+
+	g.mu.Lock()
+	g.rateLimitStatus, g.rateLimitStatusAt = status, time.Now()
+	g.mu.Unlock()
+	return status, nil
+}
+
+// githubAPIVersion pins the REST API version requested with every call, so
+// responses don't change shape when GitHub releases a new one
+const githubAPIVersion = "2022-11-28"
+
+// newRequest builds an authenticated GET request against the GitHub API. The
+// Bearer scheme works for classic, fine-grained and GitHub App tokens alike.
+func (g *GitHubService) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", githubAPIVersion)
+	return req, nil
+}
+
+// doRequest sends a GitHub API request. It tracks the X-RateLimit-* response
+// headers and, once the limit is exhausted, sleeps until the reset time before
+// issuing further requests. Secondary rate limit rejections are retried after
+// their Retry-After delay. Network errors and 5xx responses are retried up to
+// MaxRetries times with exponential backoff; other 4xx responses are returned
+// as-is.
+func (g *GitHubService) doRequest(req *http.Request) (*http.Response, error) {
+	if g.UserAgent != "" {
+		req.Header.Set("User-Agent", g.UserAgent)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: g.Transport}
+	retries := 0
+	var secondaryWait time.Duration
+	for attempt := 0; ; attempt++ {
+		if err := g.waitForRateLimit(req.Context(), g.rateLimitResource(req)); err != nil {
+			return nil, err
+		}
+
+		// A request with a body (such as a GraphQL query) needs a fresh copy
+		// of it every time it is resent
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		g.observeRequest(resp, err)
+		if err != nil {
+			if retries >= g.MaxRetries || req.Context().Err() != nil {
+				return nil, err
+			}
+			retries++
+			slog.WarnContext(req.Context(), "GitHub request failed, retrying", "url", req.URL.String(), "attempt", retries, "error", err)
+			if err := sleepContext(req.Context(), retryBackoff(retries)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		g.recordRateLimit(resp.Header)
+		slog.DebugContext(req.Context(), "GitHub request", "url", req.URL.String(), "status_code", resp.StatusCode, "duration", time.Since(start))
+
+		// A rejected request with no remaining budget is retried after the reset.
+		// The retries count against MaxRetries and back off at least as long as
+		// other failures, so a missing or already passed reset time can't turn
+		// this into a busy loop.
+		if (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) &&
+			resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			resp.Body.Close()
+			if retries >= g.MaxRetries {
+				return nil, &RateLimitError{Reset: rateLimitReset(resp.Header)}
+			}
+			retries++
+			slog.WarnContext(req.Context(), "GitHub rate limit exhausted, retrying", "url", req.URL.String(), "attempt", retries)
+			if err := sleepContext(req.Context(), retryBackoff(retries)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// The secondary rate limit (abuse detection) rejects bursts of requests
+		// even with budget left, and says how long to back off in Retry-After
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				resp.Body.Close()
+				wait := time.Duration(seconds) * time.Second
+				if secondaryWait+wait > g.MaxSecondaryRateLimitWait {
+					return nil, &SecondaryRateLimitError{RetryAfter: wait}
+				}
+				secondaryWait += wait
+				slog.WarnContext(req.Context(), "GitHub secondary rate limit hit, backing off", "url", req.URL.String(), "retry_after", wait, "total_wait", secondaryWait)
+				if err := sleepContext(req.Context(), wait); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		if resp.StatusCode >= 500 && retries < g.MaxRetries {
+			resp.Body.Close()
+			retries++
+			slog.WarnContext(req.Context(), "GitHub request failed, retrying", "url", req.URL.String(), "attempt", retries, "status_code", resp.StatusCode)
+			if err := sleepContext(req.Context(), retryBackoff(retries)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return resp, nil
+	}
 }
 
-func NewGitHubService() *GitHubService {
-	token := os.Getenv("GITHUB_TOKEN")
-	return &GitHubService{Token: token}
+// observeRequest reports a response's status code to the ObserveRequest hook
+func (g *GitHubService) observeRequest(resp *http.Response, err error) {
+	if g.ObserveRequest == nil {
+		return
+	}
+	if err != nil {
+		g.ObserveRequest("error")
+		return
+	}
+	g.ObserveRequest(strconv.Itoa(resp.StatusCode))
+}
+
+// retryBackoff returns the delay before the given retry attempt: one second
+// doubled for each previous attempt, plus up to 50% random jitter
+func retryBackoff(attempt int) time.Duration {
+	backoff := time.Second << (attempt - 1)
+	return backoff + time.Duration(rand.Int63n(int64(backoff/2)+1))
 }
 
-func (g *GitHubService) FetchUserActivity(username string) ([]GitHubActivity, error) {
+// sleepContext sleeps for d, returning early with the context's error if it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getJSON performs a GitHub API request and decodes a successful response into v.
+// The response body is always closed before returning, so it is safe to call in
+// pagination loops. Non-200 statuses are returned without decoding.
+func (g *GitHubService) getJSON(ctx context.Context, url string, v interface{}) (int, error) {
+	status, _, err := g.fetchJSON(ctx, url, v, false)
+	return status, err
+}
+
+// getJSONConditional behaves like getJSON but sends the ETag from a previous
+// response as If-None-Match. A 304 Not Modified, which doesn't count against the
+// rate limit, is answered from the cached payload and reported as a 200.
+func (g *GitHubService) getJSONConditional(ctx context.Context, url string, v interface{}) (int, error) {
+	status, _, err := g.fetchJSON(ctx, url, v, true)
+	return status, err
+}
+
+// fetchJSON implements getJSON and getJSONConditional, also returning the
+// response headers. The headers are nil when the response was answered from the
+// disk or ETag cache.
+func (g *GitHubService) fetchJSON(ctx context.Context, url string, v interface{}, conditional bool) (int, http.Header, error) {
+	if body, ok := g.DiskCache.get(ctx, url); ok {
+		return http.StatusOK, nil, json.Unmarshal(body, v)
+	}
+
+	req, err := g.newRequest(ctx, url)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	conditional = conditional && g.Cache != nil
+	var etag string
+	var cached []byte
+	var found bool
+	if conditional {
+		etag, cached, found = g.Cache.Get(url)
+		if found {
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+
+	resp, err := g.doRequest(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && found {
+		g.DiskCache.put(ctx, url, cached)
+		return http.StatusOK, nil, json.Unmarshal(cached, v)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, resp.Header, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, resp.Header, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, resp.Header, err
+	}
+	g.DiskCache.put(ctx, url, body)
+
+	if conditional {
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			if err := g.Cache.Put(url, etag, body); err != nil {
+				slog.WarnContext(ctx, "failed to cache GitHub response", "url", url, "error", err)
+			}
+		}
+	}
+	return resp.StatusCode, resp.Header, nil
+}
+
+// rateLimitResource names the rate limit resource a request is counted against
+func (g *GitHubService) rateLimitResource(req *http.Request) string {
+	url := req.URL.String()
+	switch {
+	case strings.HasPrefix(url, g.APIBase+"/search/"):
+		return "search"
+	case strings.HasPrefix(url, g.graphQLURL()):
+		return "graphql"
+	default:
+		return "core"
+	}
+}
+
+// recordRateLimit stores the rate limit state reported by a GitHub response
+func (g *GitHubService) recordRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset := rateLimitReset(header)
+	if reset.IsZero() {
+		return
+	}
+
+	resource := header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.rateLimits == nil {
+		g.rateLimits = make(map[string]rateLimitState)
+	}
+	g.rateLimits[resource] = rateLimitState{remaining: remaining, reset: reset}
+}
+
+// rateLimitReset returns the reset time a response reports in X-RateLimit-Reset,
+// or the zero time if it is missing or malformed
+func rateLimitReset(header http.Header) time.Time {
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(reset, 0)
+}
+
+// waitForRateLimit blocks until the rate limit resets when it has been exhausted,
+// or returns a RateLimitError if the wait would exceed MaxRateLimitWait. The wait
+// ends early with the context's error if it is cancelled.
+func (g *GitHubService) waitForRateLimit(ctx context.Context, resource string) error {
+	g.mu.Lock()
+	state := g.rateLimits[resource]
+	g.mu.Unlock()
+	remaining, reset := state.remaining, state.reset
+
+	if remaining > 0 || reset.IsZero() {
+		return nil
+	}
+
+	wait := time.Until(reset)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > g.MaxRateLimitWait {
+		return &RateLimitError{Reset: reset}
+	}
+
+	slog.WarnContext(ctx, "GitHub rate limit exhausted, waiting for reset", "resource", resource, "wait", wait.Round(time.Second), "reset", reset)
+	return sleepContext(ctx, wait)
+}
+
+// FetchUserActivity collects the user's activity over the history window. A
+// repository's commits are listed from its watermark, the newest commit date
+// stored by an earlier refresh, when that is within the window.
+func (g *GitHubService) FetchUserActivity(ctx context.Context, username string, watermarks map[string]time.Time, progress ProgressFunc) (UserActivity, error) {
 	if g.Token == "" {
 		// Return sample data if no token is provided
-		return g.getSampleData(), nil
+		progress.report("no GitHub token configured, using sample data")
+		return UserActivity{Activities: g.getSampleData(), Repos: g.getSampleRepos()}, nil
+	}
+
+	if g.UseGraphQL && len(g.CommitAuthors) > 0 {
+		// contributionsCollection only counts commits attributed to the user's
+		// own account, so secondary identities need the REST walk
+		slog.InfoContext(ctx, "GITHUB_COMMIT_AUTHORS is set, fetching via REST instead of GraphQL")
+	} else if g.UseGraphQL {
+		activity, err := g.FetchUserActivityGraphQL(ctx, username, progress)
+		if err == nil {
+			return activity, nil
+		}
+		if ctx.Err() != nil {
+			return UserActivity{}, ctx.Err()
+		}
+		slog.WarnContext(ctx, "GraphQL fetch failed, falling back to REST", "error", err)
+		progress.report("GraphQL fetch failed, falling back to REST")
 	}
 
 	// First fetch user repos
-	repos, err := g.fetchUserRepos(username)
+	owned, err := g.fetchRepos(ctx, username)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch user repos: %w", err)
+		return UserActivity{}, err
+	}
+	owned = g.filterRepos(owned)
+	since := g.HistoryStart()
+
+	// Add repositories the user committed to without owning them
+	repos := owned
+	contributed, err := g.fetchContributedRepos(ctx, username, since)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to search for contributed repos", "error", err)
+	}
+	known := make(map[string]bool)
+	for _, repo := range owned {
+		known[repo.FullName] = true
+	}
+	for _, repo := range g.filterRepos(contributed) {
+		if !known[repo.FullName] {
+			known[repo.FullName] = true
+			repos = append(repos, repo)
+		}
 	}
 
+	repos = g.capRepos(ctx, repos)
+
 	// Then fetch commits for each repo
 	var allActivities []GitHubActivity
-	sixMonthsAgo := time.Now().AddDate(0, -6, 0)
+	walked := make(map[string]bool) // repos whose commits were listed directly
+	newest := make(map[string]time.Time)
+	var gone []string
+	progress.report("found %d repositories", len(repos))
+
+	for i, repo := range repos {
+		// Stop early once the caller has gone away rather than logging a
+		// failure for every remaining repository
+		if err := ctx.Err(); err != nil {
+			return UserActivity{}, err
+		}
 
-	for _, repo := range repos {
-		commits, err := g.fetchRepoCommits(username, repo.Name, sixMonthsAgo)
+		progress.report("fetching repo %s (%d of %d)", repo.FullName, i+1, len(repos))
+		start := time.Now()
+		commitsSince := since
+		if watermark, ok := watermarks[repo.FullName]; ok && watermark.After(since) {
+			commitsSince = watermark
+		}
+		commits, err := g.fetchAuthoredCommits(ctx, repo.FullName, username, commitsSince)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// Not a failure: the repository was deleted or renamed since the listing
+			slog.InfoContext(ctx, "repository no longer exists, skipping", "repo", repo.FullName)
+			gone = append(gone, repo.FullName)
+			continue
+		}
 		if err != nil {
 			// Log error but continue with other repos
-			fmt.Printf("Warning: Failed to fetch commits for %s: %v\n", repo.Name, err)
+			logFetchWarning(ctx, "failed to fetch commits", repo.FullName, start, err)
 			continue
 		}
 		allActivities = append(allActivities, commits...)
+		walked[repo.FullName] = true
+		for _, commit := range commits {
+			if commit.Date.After(newest[repo.FullName]) {
+				newest[repo.FullName] = commit.Date
+			}
+		}
+
+		prs, err := g.fetchRepoPullRequests(ctx, repo.FullName, since)
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			slog.InfoContext(ctx, "repository no longer exists, skipping", "repo", repo.FullName)
+			gone = append(gone, repo.FullName)
+			continue
+		}
+		if err != nil {
+			logFetchWarning(ctx, "failed to fetch pull requests", repo.FullName, start, err)
+			continue
+		}
+		allActivities = append(allActivities, g.convertPullRequestsToActivity(prs, repo.FullName, username)...)
+
+		issues, err := g.fetchRepoIssues(ctx, repo.FullName, username, since)
+		if err != nil {
+			logFetchWarning(ctx, "failed to fetch issues", repo.FullName, start, err)
+			continue
+		}
+		allActivities = append(allActivities, g.convertIssuesToActivity(issues, repo.FullName)...)
 	}
 
-	// Also fetch recent events for other activity types
-	events, err := g.fetchRecentEvents(username)
+	// Also fetch recent events for other activity types, and for commits to
+	// repositories that weren't walked above (such as other people's projects)
+	progress.report("fetching recent events")
+	events, err := g.fetchRecentEvents(ctx, username)
 	if err == nil {
-		allActivities = append(allActivities, g.convertEventsToActivity(events)...)
+		eventActivities := canonicalRepoNames(g.convertEventsToActivity(events), repos)
+		eventActivities = g.dropWalkedCommits(eventActivities, walked)
+		allActivities = append(allActivities, g.dropKnownItems(eventActivities, allActivities)...)
 	}
 
-	return allActivities, nil
+	private := make(map[string]bool)
+	for _, repo := range repos {
+		private[repo.FullName] = repo.Private
+	}
+	for i, activity := range allActivities {
+		if private[activity.Repository] {
+			allActivities[i].Private = true
+		}
+	}
+
+	// Only owned repositories carry star and fork counts worth snapshotting
+	return UserActivity{Activities: g.dropPrivate(allActivities), Repos: owned, Since: since, CommitWatermarks: newest, GoneRepos: gone}, nil
+}
+
+// dropPrivate removes activity in private repositories unless IncludePrivate
+// is set
+func (g *GitHubService) dropPrivate(activities []GitHubActivity) []GitHubActivity {
+	if g.IncludePrivate {
+		return activities
+	}
+	var public []GitHubActivity
+	for _, activity := range activities {
+		if !activity.Private {
+			public = append(public, activity)
+		}
+	}
+	return public
+}
+
+// errRepoExcluded is returned for a repository skipped by the repository
+// filters (RepoInclude/RepoExclude, IncludeForks and IncludePrivate)
+var errRepoExcluded = errors.New("repository is excluded by the configured filters")
+
+// FetchRepoActivity collects the user's commits, pull requests and issues in a
+// single repository, as FetchUserActivity does for each repository it walks.
+// Commits are listed from watermark when that is within the history window. A
+// repository GitHub doesn't know is reported as an APIError with status 404.
+func (g *GitHubService) FetchRepoActivity(ctx context.Context, repoFullName, username string, watermark time.Time) (UserActivity, error) {
+	if g.Token == "" {
+		return g.sampleRepoActivity(repoFullName)
+	}
+
+	var repo GitHubRepo
+	status, err := g.getJSON(ctx, fmt.Sprintf("%s/repos/%s", g.APIBase, repoFullName), &repo)
+	if err != nil {
+		return UserActivity{}, err
+	}
+	if status != http.StatusOK {
+		return UserActivity{}, &APIError{StatusCode: status, Repo: repoFullName}
+	}
+	if len(g.filterRepos([]GitHubRepo{repo})) == 0 {
+		return UserActivity{}, errRepoExcluded
+	}
+
+	since := g.HistoryStart()
+	commitsSince := since
+	if watermark.After(since) {
+		commitsSince = watermark
+	}
+
+	commits, err := g.fetchAuthoredCommits(ctx, repo.FullName, username, commitsSince)
+	if err != nil {
+		return UserActivity{}, fmt.Errorf("failed to fetch commits: %w", err)
+	}
+	prs, err := g.fetchRepoPullRequests(ctx, repo.FullName, since)
+	if err != nil {
+		return UserActivity{}, fmt.Errorf("failed to fetch pull requests: %w", err)
+	}
+	issues, err := g.fetchRepoIssues(ctx, repo.FullName, username, since)
+	if err != nil {
+		return UserActivity{}, fmt.Errorf("failed to fetch issues: %w", err)
+	}
+
+	result := UserActivity{Repos: []GitHubRepo{repo}, Since: since, CommitWatermarks: make(map[string]time.Time)}
+	for _, commit := range commits {
+		if commit.Date.After(result.CommitWatermarks[repo.FullName]) {
+			result.CommitWatermarks[repo.FullName] = commit.Date
+		}
+	}
+	result.Activities = append(result.Activities, commits...)
+	result.Activities = append(result.Activities, g.convertPullRequestsToActivity(prs, repo.FullName, username)...)
+	result.Activities = append(result.Activities, g.convertIssuesToActivity(issues, repo.FullName)...)
+	for i := range result.Activities {
+		result.Activities[i].Private = repo.Private
+	}
+	return result, nil
+}
+
+// sampleRepoActivity is FetchRepoActivity in sample mode: the sample activity
+// of one repository
+func (g *GitHubService) sampleRepoActivity(repoFullName string) (UserActivity, error) {
+	var result UserActivity
+	for _, activity := range g.getSampleData() {
+		if activity.Repository == repoFullName {
+			result.Activities = append(result.Activities, activity)
+		}
+	}
+	for _, repo := range g.getSampleRepos() {
+		if repo.FullName == repoFullName {
+			result.Repos = append(result.Repos, repo)
+		}
+	}
+	if len(result.Activities) == 0 && len(result.Repos) == 0 {
+		return UserActivity{}, &APIError{StatusCode: http.StatusNotFound, Repo: repoFullName}
+	}
+	return result, nil
+}
+
+// dropKnownItems removes event-derived activities describing an item (such as a
+// pull request) that was already fetched directly, so it isn't stored twice
+func (g *GitHubService) dropKnownItems(eventActivities, fetched []GitHubActivity) []GitHubActivity {
+	known := make(map[string]bool)
+	for _, activity := range fetched {
+		known[activity.Repository+"#"+activity.GitHubID] = true
+	}
+
+	var filtered []GitHubActivity
+	for _, activity := range eventActivities {
+		if known[activity.Repository+"#"+activity.GitHubID] {
+			continue
+		}
+		filtered = append(filtered, activity)
+	}
+	return filtered
+}
+
+// canonicalRepoNames rewrites each activity's repository to the owner/name
+// spelling from the repos payload's full_name. Event payloads name repositories
+// case-insensitively, so without this the same repository could be stored
+// under two names.
+func canonicalRepoNames(activities []GitHubActivity, repos []GitHubRepo) []GitHubActivity {
+	canonical := make(map[string]string)
+	for _, repo := range repos {
+		canonical[strings.ToLower(repo.FullName)] = repo.FullName
+	}
+
+	for i, activity := range activities {
+		if name, ok := canonical[strings.ToLower(activity.Repository)]; ok {
+			activities[i].Repository = name
+		}
+	}
+	return activities
+}
+
+// dropWalkedCommits removes PushEvent-derived commits for repositories whose
+// commits were listed directly, since the commit listing is authoritative, and
+// events for repositories filtered out by RepoInclude/RepoExclude
+func (g *GitHubService) dropWalkedCommits(eventActivities []GitHubActivity, walked map[string]bool) []GitHubActivity {
+	var filtered []GitHubActivity
+	for _, activity := range eventActivities {
+		if activity.ActivityType == "commit" && walked[activity.Repository] {
+			continue
+		}
+		if !g.repoAllowed(activity.Repository) {
+			continue
+		}
+		filtered = append(filtered, activity)
+	}
+	return filtered
 }
 
 func (g *GitHubService) convertEventsToActivity(events []GitHubEvent) []GitHubActivity {
@@ -119,8 +1046,10 @@ func (g *GitHubService) convertEventsToActivity(events []GitHubEvent) []GitHubAc
 	for _, event := range events {
 		activityType := g.getActivityType(event.Type)
 		githubID := event.ID
-		url := fmt.Sprintf("https://github.com/%s", event.Repo.Name)
-		
+		url := fmt.Sprintf("%s/%s", g.WebBase, event.Repo.Name)
+		var number int
+		var title, state string
+
 		// Extract specific IDs and URLs from payload based on event type
 		switch event.Type {
 		case "PullRequestEvent":
@@ -141,9 +1070,29 @@ func (g *GitHubService) convertEventsToActivity(events []GitHubEvent) []GitHubAc
 					}
 				}
 			}
+		case "PullRequestReviewEvent":
+			// Keep the event ID as the review's identity, as before, but record
+			// which pull request was reviewed and the review's verdict
+			if pr, ok := event.Payload["pull_request"].(map[string]interface{}); ok {
+				if n, ok := pr["number"].(float64); ok {
+					number = int(n)
+				}
+				title, _ = pr["title"].(string)
+				if htmlURL, ok := pr["html_url"].(string); ok {
+					url = htmlURL
+				}
+			}
+			if review, ok := event.Payload["review"].(map[string]interface{}); ok {
+				state, _ = review["state"].(string)
+				state = strings.ToLower(state)
+				if htmlURL, ok := review["html_url"].(string); ok {
+					url = htmlURL
+				}
+			}
 		case "PushEvent":
-			// Skip push events as we already track individual commits separately
-			// via the fetchRepoCommits function which provides more detailed information
+			// Record each pushed commit by SHA so it lines up with the rows
+			// produced by fetchRepoCommits and is never counted twice
+			activities = append(activities, g.convertPushEventCommits(event)...)
 			continue
 		}
 
@@ -154,164 +1103,382 @@ func (g *GitHubService) convertEventsToActivity(events []GitHubEvent) []GitHubAc
 			Count:        1,
 			URL:          url,
 			GitHubID:     githubID,
+			Number:       number,
+			Title:        title,
+			State:        state,
+			Private:      !event.Public,
 		})
 	}
 
 	return activities
 }
 
-func (g *GitHubService) fetchUserRepos(username string) ([]GitHubRepo, error) {
-	var allRepos []GitHubRepo
-	page := 1
-	perPage := 100
+// convertPushEventCommits turns the commits listed in a PushEvent payload into
+// commit activities. Commits that were already on the remote (distinct=false)
+// are skipped.
+func (g *GitHubService) convertPushEventCommits(event GitHubEvent) []GitHubActivity {
+	commits, _ := event.Payload["commits"].([]interface{})
 
-	for {
-		url := fmt.Sprintf("https://api.github.com/users/%s/repos?type=all&sort=pushed&per_page=%d&page=%d", username, perPage, page)
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
+	var activities []GitHubActivity
+	for _, c := range commits {
+		commit, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sha, _ := commit["sha"].(string)
+		if sha == "" {
+			continue
 		}
+		if distinct, ok := commit["distinct"].(bool); ok && !distinct {
+			continue
+		}
+		message, _ := commit["message"].(string)
+
+		activities = append(activities, GitHubActivity{
+			Date:         event.CreatedAt,
+			Repository:   event.Repo.Name,
+			ActivityType: "commit",
+			Count:        1,
+			URL:          fmt.Sprintf("%s/%s/commit/%s", g.WebBase, event.Repo.Name, sha),
+			GitHubID:     sha,
+			Message:      message,
+			Private:      !event.Public,
+		})
+	}
+	return activities
+}
 
-		req.Header.Set("Authorization", "token "+g.Token)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
+// fetchRepos returns the user's repositories together with those of every
+// configured organization, deduplicated by full name
+func (g *GitHubService) fetchRepos(ctx context.Context, username string) ([]GitHubRepo, error) {
+	repos, err := g.fetchUserRepos(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user repos: %w", err)
+	}
 
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
+	for _, org := range g.Orgs {
+		orgRepos, err := g.fetchOrgRepos(ctx, org)
 		if err != nil {
-			return nil, err
+			// Log error but continue with the repos we have
+			slog.WarnContext(ctx, "failed to fetch organization repos", "org", org, "error", err)
+			continue
 		}
-		defer resp.Body.Close()
+		repos = append(repos, orgRepos...)
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
+	seen := make(map[string]bool)
+	var unique []GitHubRepo
+	for _, repo := range repos {
+		if seen[repo.FullName] {
+			continue
 		}
+		seen[repo.FullName] = true
+		unique = append(unique, repo)
+	}
+
+	return unique, nil
+}
+
+func (g *GitHubService) fetchUserRepos(ctx context.Context, username string) ([]GitHubRepo, error) {
+	return g.fetchRepoListing(ctx, fmt.Sprintf("%s/users/%s/repos?type=all&sort=pushed", g.APIBase, username))
+}
+
+func (g *GitHubService) fetchOrgRepos(ctx context.Context, org string) ([]GitHubRepo, error) {
+	return g.fetchRepoListing(ctx, fmt.Sprintf("%s/orgs/%s/repos?type=all&sort=pushed", g.APIBase, org))
+}
+
+// fetchRepoListing pages through a repository listing endpoint
+func (g *GitHubService) fetchRepoListing(ctx context.Context, baseURL string) ([]GitHubRepo, error) {
+	return doPaginated[GitHubRepo](ctx, g, paginatedRequest{URL: baseURL, Conditional: true})
+}
 
-		var repos []GitHubRepo
-		if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
+// paginatedRequest describes a GitHub list endpoint fetched by doPaginated
+type paginatedRequest struct {
+	// URL is the endpoint including its query string, without per_page or page
+	URL string
+	// Repo is reported in APIErrors for per-repository endpoints
+	Repo string
+	// Conditional sends cached ETags so unchanged pages don't use rate limit
+	Conditional bool
+	// ConflictMeansEmpty treats a 409 Conflict, which GitHub returns for the
+	// commits of an empty repository, as the end of the listing
+	ConflictMeansEmpty bool
+}
+
+// doPaginated fetches every page of a GitHub list endpoint, 100 items at a
+// time, following the Link header until there is no next page or a page is
+// empty. A non-200
+// status is returned as an APIError, except for a 409 with ConflictMeansEmpty,
+// which ends the listing with whatever earlier pages returned.
+func doPaginated[T any](ctx context.Context, g *GitHubService, req paginatedRequest) ([]T, error) {
+	separator := "?"
+	if strings.Contains(req.URL, "?") {
+		separator = "&"
+	}
+
+	var all []T
+	url := fmt.Sprintf("%s%sper_page=%d&page=1", req.URL, separator, perPage)
+	for url != "" {
+		items, status, next, err := getPage[T](ctx, g, url, req.Conditional)
+		if err != nil {
 			return nil, err
 		}
-
-		if len(repos) == 0 {
+		if status == http.StatusConflict && req.ConflictMeansEmpty {
 			break
 		}
+		if status != http.StatusOK {
+			return nil, &APIError{StatusCode: status, Repo: req.Repo}
+		}
 
-		allRepos = append(allRepos, repos...)
-		
-		// Check if we got less than perPage, meaning we've reached the end
-		if len(repos) < perPage {
+		// An empty page ends the listing even if a next link was sent
+		if len(items) == 0 {
 			break
 		}
-		
-		page++
+		all = append(all, items...)
+		url = next
 	}
 
-	return allRepos, nil
+	return all, nil
 }
 
-func (g *GitHubService) fetchRepoCommits(username, repoName string, since time.Time) ([]GitHubActivity, error) {
-	var allCommits []GitHubCommit
-	page := 1
-	perPage := 100
+// perPage is the page size requested from GitHub list endpoints
+const perPage = 100
+
+// getPage fetches one page of a GitHub list endpoint. next is the URL of the
+// following page, from the rel="next" entry of the Link header, or "" on the
+// last page. Pages answered from a cache have no headers, so for those a full
+// page is taken to mean there is another one.
+func getPage[T any](ctx context.Context, g *GitHubService, url string, conditional bool) (items []T, status int, next string, err error) {
+	status, header, err := g.fetchJSON(ctx, url, &items, conditional)
+	if err != nil || status != http.StatusOK {
+		return nil, status, "", err
+	}
+
+	if header != nil {
+		next = nextPageURL(header)
+	} else if len(items) >= perPage {
+		next = followingPageURL(url)
+	}
+	return items, status, next, nil
+}
 
-	for {
-		url := fmt.Sprintf("https://api.github.com/repos/%s/%s/commits?author=%s&since=%s&per_page=%d&page=%d", 
-			username, repoName, username, since.Format(time.RFC3339), perPage, page)
-		
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
+// nextPageURL returns the rel="next" link of a GitHub response's Link header,
+// which looks like: <https://api.github.com/...&page=2>; rel="next", <...>; rel="last"
+func nextPageURL(header http.Header) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
 		}
+	}
+	return ""
+}
 
-		req.Header.Set("Authorization", "token "+g.Token)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
+// pageParam matches the page query parameter, but not per_page
+var pageParam = regexp.MustCompile(`([?&])page=(\d+)`)
 
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
+// followingPageURL returns url with its page parameter incremented
+func followingPageURL(url string) string {
+	match := pageParam.FindStringSubmatch(url)
+	if match == nil {
+		return ""
+	}
+	page, _ := strconv.Atoi(match[2])
+	return pageParam.ReplaceAllString(url, "${1}page="+strconv.Itoa(page+1))
+}
 
-		if resp.StatusCode == 409 {
-			// Repository is empty, skip it
-			return []GitHubActivity{}, nil
-		}
+// searchCommitsResult is the subset of a commit search response we use
+type searchCommitsResult struct {
+	TotalCount int `json:"total_count"`
+	Items      []struct {
+		Repository GitHubRepo `json:"repository"`
+	} `json:"items"`
+}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("GitHub API returned status: %d for repo %s", resp.StatusCode, repoName)
+// fetchContributedRepos finds the repositories the user authored commits in
+// since the given time using the commit search API. Search has its own, much
+// lower rate limit and only returns the first 1000 results, so the number of
+// pages is capped.
+func (g *GitHubService) fetchContributedRepos(ctx context.Context, username string, since time.Time) ([]GitHubRepo, error) {
+	const maxPages = 10
+
+	seen := make(map[string]bool)
+	var repos []GitHubRepo
+	for page := 1; page <= maxPages; page++ {
+		query := fmt.Sprintf("author:%s committer-date:>=%s", username, since.Format("2006-01-02"))
+		url := fmt.Sprintf("%s/search/commits?q=%s&sort=committer-date&order=desc&per_page=%d&page=%d",
+			g.APIBase, neturl.QueryEscape(query), perPage, page)
+
+		var result searchCommitsResult
+		status, err := g.getJSON(ctx, url, &result)
+		if err != nil {
+			return repos, err
 		}
-
-		var commits []GitHubCommit
-		if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
-			return nil, err
+		if status != http.StatusOK {
+			return repos, &APIError{StatusCode: status}
 		}
 
-		if len(commits) == 0 {
-			break
+		for _, item := range result.Items {
+			if item.Repository.FullName == "" || seen[item.Repository.FullName] {
+				continue
+			}
+			seen[item.Repository.FullName] = true
+			repos = append(repos, item.Repository)
 		}
 
-		allCommits = append(allCommits, commits...)
-		
-		// Check if we got less than perPage, meaning we've reached the end
-		if len(commits) < perPage {
+		if len(result.Items) < perPage || page*perPage >= result.TotalCount {
 			break
 		}
-		
-		page++
 	}
 
-	return g.convertCommitsToActivity(allCommits, repoName, username), nil
+	return repos, nil
 }
 
-func (g *GitHubService) fetchRecentEvents(username string) ([]GitHubEvent, error) {
-	url := fmt.Sprintf("https://api.github.com/users/%s/events", username)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// fetchAuthoredCommits lists a repository's commits by each of CommitAuthors
+// (or just username), once per author since the API takes a single one, and
+// merges them by SHA
+func (g *GitHubService) fetchAuthoredCommits(ctx context.Context, repoFullName, username string, since time.Time) ([]GitHubActivity, error) {
+	authors := g.CommitAuthors
+	if len(authors) == 0 {
+		authors = []string{username}
+	}
+
+	var all []GitHubActivity
+	seen := make(map[string]bool)
+	for _, author := range authors {
+		commits, err := g.fetchRepoCommits(ctx, repoFullName, author, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range commits {
+			if !seen[commit.GitHubID] {
+				seen[commit.GitHubID] = true
+				all = append(all, commit)
+			}
+		}
 	}
+	return all, nil
+}
 
-	req.Header.Set("Authorization", "token "+g.Token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+// sinceParam formats since for a listing URL, rounded down to its UTC day.
+// The history window starts a little later on every refresh, so the exact
+// time would give each refresh new URLs that never hit the disk or ETag
+// cache. The extra hours listed are stored by GitHub id like the rest.
+func sinceParam(since time.Time) string {
+	return since.UTC().Truncate(24 * time.Hour).Format(time.RFC3339)
+}
+
+func (g *GitHubService) fetchRepoCommits(ctx context.Context, repoFullName, author string, since time.Time) ([]GitHubActivity, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits?author=%s&since=%s",
+		g.APIBase, repoFullName, neturl.QueryEscape(author), sinceParam(since))
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	// An empty repository answers 409 and simply has no commits
+	commits, err := doPaginated[GitHubCommit](ctx, g, paginatedRequest{URL: url, Repo: repoFullName, ConflictMeansEmpty: true})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
-	}
+	return g.convertCommitsToActivity(commits, repoFullName), nil
+}
 
+func (g *GitHubService) fetchRecentEvents(ctx context.Context, username string) ([]GitHubEvent, error) {
+	url := fmt.Sprintf("%s/users/%s/events", g.APIBase, username)
 	var events []GitHubEvent
-	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+	status, err := g.getJSON(ctx, url, &events)
+	if err != nil {
 		return nil, err
 	}
 
-	// Filter events to last 6 months
-	sixMonthsAgo := time.Now().AddDate(0, -6, 0)
+	if status != http.StatusOK {
+		return nil, &APIError{StatusCode: status}
+	}
+
+	// Filter events to the configured history window
+	since := g.HistoryStart()
 	var recentEvents []GitHubEvent
 	for _, event := range events {
-		if event.CreatedAt.After(sixMonthsAgo) {
+		if event.CreatedAt.After(since) {
 			recentEvents = append(recentEvents, event)
 		}
 	}
 	return recentEvents, nil
 }
 
-func (g *GitHubService) convertCommitsToActivity(commits []GitHubCommit, repoName, username string) []GitHubActivity {
+func (g *GitHubService) convertCommitsToActivity(commits []GitHubCommit, repoFullName string) []GitHubActivity {
 	// Store each commit individually with its unique SHA
 	var activities []GitHubActivity
-	
+
 	for _, commit := range commits {
+		url := commit.URL
+		if url == "" {
+			url = fmt.Sprintf("%s/%s/commit/%s", g.WebBase, repoFullName, commit.SHA)
+		}
 		activities = append(activities, GitHubActivity{
 			Date:         commit.Commit.Author.Date,
-			Repository:   fmt.Sprintf("%s/%s", username, repoName),
+			Repository:   repoFullName,
 			ActivityType: "commit",
 			Count:        1,
-			URL:          commit.URL,
+			URL:          url,
 			GitHubID:     commit.SHA,
+			Message:      commit.Commit.Message,
+		})
+	}
+
+	return activities
+}
+
+// convertPullRequestsToActivity stores each pull request authored by username
+// individually, keyed by its number
+func (g *GitHubService) convertPullRequestsToActivity(prs []GitHubPullRequest, repoFullName, username string) []GitHubActivity {
+	var activities []GitHubActivity
+
+	for _, pr := range prs {
+		// Logins are case-insensitive, so GITHUB_USERNAME may be cased differently
+		if !strings.EqualFold(pr.User.Login, username) {
+			continue
+		}
+
+		state := pr.State
+		if pr.MergedAt != nil {
+			state = "merged"
+		}
+
+		activities = append(activities, GitHubActivity{
+			Date:         pr.CreatedAt,
+			Repository:   repoFullName,
+			ActivityType: "pull_request",
+			Count:        1,
+			URL:          pr.HTMLURL,
+			GitHubID:     fmt.Sprintf("pr-%d", pr.Number),
+			Number:       pr.Number,
+			Title:        pr.Title,
+			State:        state,
+		})
+	}
+
+	return activities
+}
+
+// convertIssuesToActivity stores each issue individually, keyed by its number
+func (g *GitHubService) convertIssuesToActivity(issues []GitHubIssue, repoFullName string) []GitHubActivity {
+	var activities []GitHubActivity
+
+	for _, issue := range issues {
+		activities = append(activities, GitHubActivity{
+			Date:         issue.CreatedAt,
+			Repository:   repoFullName,
+			ActivityType: "issue",
+			Count:        1,
+			URL:          issue.HTMLURL,
+			GitHubID:     fmt.Sprintf("issue-%d", issue.Number),
+			Number:       issue.Number,
+			Title:        issue.Title,
+			State:        issue.State,
 		})
 	}
 
@@ -339,7 +1506,70 @@ func (g *GitHubService) getActivityType(eventType string) string {
 	}
 }
 
+// sampleUsername owns the built-in sample repositories. The feeds are titled
+// after it when the app runs on sample data without a GITHUB_USERNAME.
+const sampleUsername = "kristofer"
+
+// getSampleRepos returns sample repositories with star and fork counts,
+// primary languages, descriptions and topics
+func (g *GitHubService) getSampleRepos() []GitHubRepo {
+	sample := []struct {
+		name         string
+		stars, forks int
+		language     string
+		description  string
+		topics       []string
+	}{
+		{"RecentRepos", 12, 3, "Go", "Timeline of recent GitHub activity", []string{"github", "timeline", "sqlite"}},
+		{"web-app", 48, 9, "TypeScript", "Single-page web application", []string{"web", "frontend"}},
+		{"another-repo", 5, 1, "Python", "Unit conversion utilities", nil},
+		{"mobile-app", 21, 4, "Swift", "Companion iOS app", []string{"ios"}},
+		{"example-project", 2, 0, "Go", "Configuration loading examples", []string{"examples"}},
+	}
+
+	var repos []GitHubRepo
+	for _, r := range sample {
+		fullName := "kristofer/" + r.name
+		repos = append(repos, GitHubRepo{
+			Name:            r.name,
+			FullName:        fullName,
+			URL:             "https://api.github.com/repos/" + fullName,
+			HTMLURL:         "https://github.com/" + fullName,
+			StargazersCount: r.stars,
+			ForksCount:      r.forks,
+			Language:        r.language,
+			Description:     r.description,
+			Topics:          r.topics,
+		})
+	}
+	return repos
+}
+
+// getSampleData returns the activities from SampleDataPath when it is set and
+// readable, and the built-in sample activities otherwise
 func (g *GitHubService) getSampleData() []GitHubActivity {
+	if g.SampleDataPath == "" {
+		return g.builtinSampleData()
+	}
+
+	data, err := os.ReadFile(g.SampleDataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return g.builtinSampleData()
+	}
+	if err != nil {
+		slog.Warn("failed to read sample data, using built-in samples", "path", g.SampleDataPath, "error", err)
+		return g.builtinSampleData()
+	}
+
+	var activities []GitHubActivity
+	if err := json.Unmarshal(data, &activities); err != nil {
+		slog.Warn("failed to parse sample data, using built-in samples", "path", g.SampleDataPath, "error", err)
+		return g.builtinSampleData()
+	}
+	return activities
+}
+
+func (g *GitHubService) builtinSampleData() []GitHubActivity {
 	now := time.Now()
 	return []GitHubActivity{
 		{
@@ -349,6 +1579,7 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/RecentRepos/commit/abc123",
 			GitHubID:     "abc123",
+			Message:      "Add timeline view",
 		},
 		{
 			Date:         now.AddDate(0, 0, -1),
@@ -357,6 +1588,7 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/RecentRepos/commit/def456",
 			GitHubID:     "def456",
+			Message:      "Fix date formatting in activity list",
 		},
 		{
 			Date:         now.AddDate(0, 0, -1),
@@ -365,6 +1597,7 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/RecentRepos/commit/ghi789",
 			GitHubID:     "ghi789",
+			Message:      "Update README with setup instructions",
 		},
 		{
 			Date:         now.AddDate(0, 0, -2),
@@ -373,6 +1606,9 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/example-project/pull/42",
 			GitHubID:     "pr-42",
+			Number:       42,
+			Title:        "Add configuration file support",
+			State:        "merged",
 		},
 		{
 			Date:         now.AddDate(0, 0, -3),
@@ -381,6 +1617,7 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/another-repo/commit/jkl012",
 			GitHubID:     "jkl012",
+			Message:      "Refactor request handling",
 		},
 		{
 			Date:         now.AddDate(0, 0, -3),
@@ -389,6 +1626,7 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/another-repo/commit/mno345",
 			GitHubID:     "mno345",
+			Message:      "Add unit conversion helpers",
 		},
 		{
 			Date:         now.AddDate(0, 0, -5),
@@ -397,6 +1635,9 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/web-app/issues/15",
 			GitHubID:     "issue-15",
+			Number:       15,
+			Title:        "Login form loses input on validation error",
+			State:        "open",
 		},
 		{
 			Date:         now.AddDate(0, 0, -5),
@@ -405,14 +1646,20 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/web-app/issues/16",
 			GitHubID:     "issue-16",
+			Number:       16,
+			Title:        "Document the deployment process",
+			State:        "closed",
 		},
 		{
 			Date:         now.AddDate(0, 0, -7),
 			Repository:   "kristofer/RecentRepos",
 			ActivityType: "review",
 			Count:        1,
-			URL:          "https://github.com/kristofer/RecentRepos",
+			URL:          "https://github.com/kristofer/RecentRepos/pull/1#pullrequestreview-1",
 			GitHubID:     "review-1",
+			Number:       1,
+			Title:        "Add initial timeline feature",
+			State:        "approved",
 		},
 		{
 			Date:         now.AddDate(0, 0, -10),
@@ -421,12 +1668,13 @@ func (g *GitHubService) getSampleData() []GitHubActivity {
 			Count:        1,
 			URL:          "https://github.com/kristofer/mobile-app/commit/pqr678",
 			GitHubID:     "pqr678",
+			Message:      "Bump minimum SDK version",
 		},
 	}
 }
 
 // FetchPRComments fetches PR comments from all repositories for a user
-func (g *GitHubService) FetchPRComments(username string) ([]PRComment, error) {
+func (g *GitHubService) FetchPRComments(ctx context.Context, username string) ([]PRComment, error) {
 	if g.Token == "" {
 		// Return sample PR comments if no token is provided
 		return g.getSamplePRComments(), nil
@@ -435,22 +1683,26 @@ func (g *GitHubService) FetchPRComments(username string) ([]PRComment, error) {
 	var allComments []PRComment
 
 	// First fetch user repos
-	repos, err := g.fetchUserRepos(username)
+	repos, err := g.fetchRepos(ctx, username)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch user repos: %w", err)
+		return nil, err
 	}
+	repos = g.filterRepos(repos)
 
-	sixMonthsAgo := time.Now().AddDate(0, -6, 0)
+	since := g.HistoryStart()
 
 	// For each repo, fetch recent PRs and their comments
 	for _, repo := range repos {
-		// Extract repo name from full name (username/repo)
-		repoName := repo.Name
-		
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+
 		// Fetch PRs for this repo
-		prs, err := g.fetchRepoPullRequests(username, repoName)
+		prs, err := g.fetchRepoPullRequests(ctx, repo.FullName, since)
 		if err != nil {
-			fmt.Printf("Warning: Failed to fetch PRs for %s: %v\n", repoName, err)
+			logFetchWarning(ctx, "failed to fetch pull requests", repo.FullName, start, err)
 			continue
 		}
 
@@ -461,19 +1713,19 @@ func (g *GitHubService) FetchPRComments(username string) ([]PRComment, error) {
 		}
 		for i := 0; i < prLimit; i++ {
 			pr := prs[i]
-			if pr.UpdatedAt.Before(sixMonthsAgo) {
+			if pr.UpdatedAt.Before(since) {
 				continue
 			}
 
 			// Fetch issue comments (PR comments on the conversation)
-			issueComments, err := g.fetchPRIssueComments(username, repoName, pr.Number)
+			issueComments, err := g.fetchPRIssueComments(ctx, repo.FullName, pr.Number)
 			if err != nil {
-				fmt.Printf("Warning: Failed to fetch issue comments for PR #%d: %v\n", pr.Number, err)
+				logFetchWarning(ctx, fmt.Sprintf("failed to fetch comments for PR #%d", pr.Number), repo.FullName, start, err)
 			} else {
 				for _, comment := range issueComments {
-					if comment.CreatedAt.After(sixMonthsAgo) {
+					if comment.CreatedAt.After(since) {
 						allComments = append(allComments, PRComment{
-							Repository: fmt.Sprintf("%s/%s", username, repoName),
+							Repository: repo.FullName,
 							PRNumber:   pr.Number,
 							PRTitle:    pr.Title,
 							Author:     comment.User.Login,
@@ -491,63 +1743,84 @@ func (g *GitHubService) FetchPRComments(username string) ([]PRComment, error) {
 	return allComments, nil
 }
 
-func (g *GitHubService) fetchRepoPullRequests(username, repoName string) ([]GitHubPullRequest, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls?state=all&sort=updated&direction=desc&per_page=10", username, repoName)
-	
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+// fetchRepoPullRequests returns the repository's pull requests in every state,
+// most recently updated first, stopping once they fall before since
+func (g *GitHubService) fetchRepoPullRequests(ctx context.Context, repoFullName string, since time.Time) ([]GitHubPullRequest, error) {
+	var allPRs []GitHubPullRequest
+	url := fmt.Sprintf("%s/repos/%s/pulls?state=all&sort=updated&direction=desc&per_page=%d&page=1",
+		g.APIBase, repoFullName, perPage)
 
-	req.Header.Set("Authorization", "token "+g.Token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	for url != "" {
+		prs, status, next, err := getPage[GitHubPullRequest](ctx, g, url, false)
+		if err != nil {
+			return nil, err
+		}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+		if status != http.StatusOK {
+			return nil, &APIError{StatusCode: status, Repo: repoFullName}
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return []GitHubPullRequest{}, nil // Return empty if no PRs or access denied
-	}
+		for _, pr := range prs {
+			if pr.UpdatedAt.Before(since) {
+				return allPRs, nil
+			}
+			allPRs = append(allPRs, pr)
+		}
 
-	var prs []GitHubPullRequest
-	if err := json.NewDecoder(resp.Body).Decode(&prs); err != nil {
-		return nil, err
+		url = next
 	}
 
-	return prs, nil
+	return allPRs, nil
 }
 
-func (g *GitHubService) fetchPRIssueComments(username, repoName string, prNumber int) ([]GitHubIssueComment, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/comments?per_page=5", username, repoName, prNumber)
-	
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// fetchRepoIssues returns the issues opened by creator in the repository that
+// were updated since the given time, excluding pull requests
+func (g *GitHubService) fetchRepoIssues(ctx context.Context, repoFullName, creator string, since time.Time) ([]GitHubIssue, error) {
+	var allIssues []GitHubIssue
+	url := fmt.Sprintf("%s/repos/%s/issues?state=all&creator=%s&since=%s&per_page=%d&page=1",
+		g.APIBase, repoFullName, creator, sinceParam(since), perPage)
+
+	for url != "" {
+		issues, status, next, err := getPage[GitHubIssue](ctx, g, url, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if status == http.StatusGone {
+			// Issues are disabled for this repository
+			return allIssues, nil
+		}
+
+		if status != http.StatusOK {
+			return nil, &APIError{StatusCode: status, Repo: repoFullName}
+		}
+
+		for _, issue := range issues {
+			// The issues API also lists pull requests, which are fetched separately
+			if issue.PullRequest == nil {
+				allIssues = append(allIssues, issue)
+			}
+		}
+
+		url = next
 	}
 
-	req.Header.Set("Authorization", "token "+g.Token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	return allIssues, nil
+}
+
+func (g *GitHubService) fetchPRIssueComments(ctx context.Context, repoFullName string, prNumber int) ([]GitHubIssueComment, error) {
+	url := fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=5", g.APIBase, repoFullName, prNumber)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	var comments []GitHubIssueComment
+	status, err := g.getJSON(ctx, url, &comments)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if status != http.StatusOK {
 		return []GitHubIssueComment{}, nil
 	}
 
-	var comments []GitHubIssueComment
-	if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
-		return nil, err
-	}
-
 	return comments, nil
 }
 
@@ -558,7 +1831,7 @@ func (g *GitHubService) getSamplePRComments() []PRComment {
 			Repository: "kristofer/RecentRepos",
 			PRNumber:   1,
 			PRTitle:    "Add initial timeline feature",
-			Author:     "kristofer",
+			Author:     sampleUsername,
 			Body:       "This looks great! The timeline view is very clean and easy to read.",
 			CreatedAt:  now.AddDate(0, 0, -1),
 			PRURL:      "https://github.com/kristofer/RecentRepos/pull/1",
@@ -578,7 +1851,7 @@ func (g *GitHubService) getSamplePRComments() []PRComment {
 			Repository: "kristofer/example-project",
 			PRNumber:   15,
 			PRTitle:    "Fix authentication bug",
-			Author:     "kristofer",
+			Author:     sampleUsername,
 			Body:       "LGTM! This fixes the issue we were seeing in production.",
 			CreatedAt:  now.AddDate(0, 0, -3),
 			PRURL:      "https://github.com/kristofer/example-project/pull/15",
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockGitHub is an httptest stand-in for the GitHub REST API serving canned
+// responses by path. Paths without a handler answer 404.
+type mockGitHub struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	routes map[string]http.HandlerFunc
+	hits   map[string]int
+}
+
+func newMockGitHub(t *testing.T) *mockGitHub {
+	t.Helper()
+	m := &mockGitHub{routes: make(map[string]http.HandlerFunc), hits: make(map[string]int)}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		m.hits[r.URL.Path]++
+		handler, ok := m.routes[r.URL.Path]
+		m.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// handle registers a handler for requests to path
+func (m *mockGitHub) handle(path string, handler http.HandlerFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes[path] = handler
+}
+
+// serveJSON answers requests to path with v encoded as JSON
+func (m *mockGitHub) serveJSON(path string, v interface{}) {
+	m.servePages(path, v)
+}
+
+// servePages answers requests to path with one of pages, chosen by the page
+// query parameter (starting at 1), linking each page but the last to the next
+// one with a rel="next" Link header as GitHub does
+func (m *mockGitHub) servePages(path string, pages ...interface{}) {
+	m.handle(path, func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		if page > len(pages) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+			return
+		}
+		if page < len(pages) {
+			// Keep the query's parameter order, as GitHub does
+			query := testPageParam.ReplaceAllString(r.URL.RawQuery, "${1}page="+strconv.Itoa(page+1))
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, m.URL, path, query))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pages[page-1])
+	})
+}
+
+// testPageParam matches the page query parameter of a raw query, but not per_page
+var testPageParam = regexp.MustCompile(`(^|&)page=\d+`)
+
+// serveStatus answers requests to path with an empty response of the status
+func (m *mockGitHub) serveStatus(path string, status int) {
+	m.handle(path, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+}
+
+// requests returns how many requests were made to path
+func (m *mockGitHub) requests(path string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hits[path]
+}
+
+// service returns a GitHubService pointed at the mock
+func (m *mockGitHub) service() *GitHubService {
+	return &GitHubService{
+		Token:            "token",
+		APIBase:          m.URL,
+		WebBase:          "https://github.com",
+		HistoryMonths:    12,
+		MaxRateLimitWait: time.Minute,
+	}
+}
+
+// activityKeys summarizes activities as sorted "type repository github_id"
+// strings for comparison
+func activityKeys(activities []GitHubActivity) []string {
+	keys := make([]string, 0, len(activities))
+	for _, activity := range activities {
+		keys = append(keys, activity.ActivityType+" "+activity.Repository+" "+activity.GitHubID)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestFetchUserActivity(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	m := newMockGitHub(t)
+	m.serveJSON("/users/octo/repos", []GitHubRepo{
+		{Name: "app", FullName: "octo/app", PushedAt: now},
+		{Name: "empty", FullName: "octo/empty", PushedAt: now.Add(-time.Hour)},
+	})
+
+	// Two pages of commits, chained by the Link header
+	m.servePages("/repos/octo/app/commits",
+		[]GitHubCommit{{SHA: "sha1", Commit: GitHubCommitData{Message: "First", Author: GitHubCommitAuthor{Date: now.Add(-2 * time.Hour)}}}},
+		[]GitHubCommit{{SHA: "sha2", Commit: GitHubCommitData{Message: "Second", Author: GitHubCommitAuthor{Date: now.Add(-time.Hour)}}}},
+	)
+	m.serveJSON("/repos/octo/app/pulls", []GitHubPullRequest{
+		{Number: 3, Title: "Add feature", State: "open", User: GitHubUser{Login: "octo"}, CreatedAt: now, UpdatedAt: now},
+		{Number: 5, Title: "Someone else's", State: "open", User: GitHubUser{Login: "other"}, CreatedAt: now, UpdatedAt: now},
+	})
+	m.serveJSON("/repos/octo/app/issues", []GitHubIssue{{Number: 4, Title: "Bug", State: "open", CreatedAt: now}})
+
+	// An empty repository answers 409 for its commits
+	m.serveStatus("/repos/octo/empty/commits", http.StatusConflict)
+	m.serveJSON("/repos/octo/empty/pulls", []GitHubPullRequest{})
+	m.serveJSON("/repos/octo/empty/issues", []GitHubIssue{})
+
+	m.serveJSON("/users/octo/events", []GitHubEvent{
+		{ID: "100", Type: "WatchEvent", Repo: GitHubRepo{Name: "other/lib"}, CreatedAt: now, Public: true},
+		{ID: "101", Type: "PushEvent", Repo: GitHubRepo{Name: "octo/app"}, CreatedAt: now, Public: true,
+			Payload: map[string]interface{}{"commits": []interface{}{map[string]interface{}{"sha": "sha2", "distinct": true}}}},
+	})
+
+	fetched, err := m.service().FetchUserActivity(context.Background(), "octo", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"commit octo/app sha1",
+		"commit octo/app sha2",
+		"issue octo/app issue-4",
+		"pull_request octo/app pr-3",
+		"star other/lib 100",
+	}
+	if got := activityKeys(fetched.Activities); !slices.Equal(got, want) {
+		t.Errorf("got activities\n%v\nwant\n%v", got, want)
+	}
+	if got := m.requests("/repos/octo/app/commits"); got != 2 {
+		t.Errorf("got %d requests for the commit pages, want 2", got)
+	}
+	if len(fetched.GoneRepos) != 0 {
+		t.Errorf("the empty repository was reported gone: %v", fetched.GoneRepos)
+	}
+	if want := now.Add(-time.Hour); !fetched.CommitWatermarks["octo/app"].Equal(want) {
+		t.Errorf("got watermark %v for octo/app, want %v", fetched.CommitWatermarks["octo/app"], want)
+	}
+}
+
+func TestFetchUserActivityPullRequests(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	m := newMockGitHub(t)
+	// GitHub matches logins in paths regardless of case too
+	m.serveJSON("/users/OCTO/repos", []GitHubRepo{
+		{Name: "app", FullName: "octo/app", PushedAt: now},
+		{Name: "moved", FullName: "octo/moved", PushedAt: now.Add(-time.Hour)},
+	})
+	m.serveJSON("/repos/octo/app/commits", []GitHubCommit{})
+	m.serveJSON("/repos/octo/app/pulls", []GitHubPullRequest{
+		{Number: 3, Title: "Add feature", State: "open", User: GitHubUser{Login: "Octo"}, CreatedAt: now, UpdatedAt: now},
+	})
+	m.serveJSON("/repos/octo/app/issues", []GitHubIssue{})
+
+	// The repository disappears between its commits and its pull requests
+	m.serveJSON("/repos/octo/moved/commits", []GitHubCommit{})
+	m.serveStatus("/repos/octo/moved/pulls", http.StatusNotFound)
+
+	// GITHUB_USERNAME cased differently from the login still matches
+	fetched, err := m.service().FetchUserActivity(context.Background(), "OCTO", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := activityKeys(fetched.Activities); !slices.Equal(got, []string{"pull_request octo/app pr-3"}) {
+		t.Errorf("got activities %v, want the pull request by Octo", got)
+	}
+	if !slices.Equal(fetched.GoneRepos, []string{"octo/moved"}) {
+		t.Errorf("got gone repositories %v, want [octo/moved]", fetched.GoneRepos)
+	}
+}
+
+func TestListingsHitDiskCacheAcrossRefreshes(t *testing.T) {
+	m := newMockGitHub(t)
+	m.serveJSON("/repos/octo/app/commits", []GitHubCommit{{SHA: "sha1"}})
+	m.serveJSON("/repos/octo/app/issues", []GitHubIssue{{Number: 4, Title: "Bug"}})
+	g := m.service()
+	g.DiskCache = newDiskCache(t.TempDir(), time.Hour)
+
+	// Two refreshes a few minutes apart start their history windows at
+	// slightly different times
+	first := time.Date(2024, 3, 1, 10, 0, 1, 0, time.UTC)
+	for _, since := range []time.Time{first, first.Add(5*time.Minute + 6*time.Second)} {
+		commits, err := g.fetchRepoCommits(context.Background(), "octo/app", "octo", since)
+		if err != nil || len(commits) != 1 {
+			t.Fatalf("got commits %v (%v), want the cached one", commits, err)
+		}
+		issues, err := g.fetchRepoIssues(context.Background(), "octo/app", "octo", since)
+		if err != nil || len(issues) != 1 {
+			t.Fatalf("got issues %v (%v), want the cached one", issues, err)
+		}
+	}
+
+	for _, path := range []string{"/repos/octo/app/commits", "/repos/octo/app/issues"} {
+		if n := m.requests(path); n != 1 {
+			t.Errorf("%s was requested %d times, want once with the second refresh served from the cache", path, n)
+		}
+	}
+}
+
+// bodyTracker is a RoundTripper that counts response bodies not yet closed,
+// and the most that were still open when a new request was sent
+type bodyTracker struct {
+	mu      sync.Mutex
+	open    int
+	maxOpen int
+}
+
+func (b *bodyTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.mu.Lock()
+	b.maxOpen = max(b.maxOpen, b.open)
+	b.mu.Unlock()
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.open++
+	b.mu.Unlock()
+	resp.Body = &trackedBody{ReadCloser: resp.Body, tracker: b}
+	return resp, nil
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	tracker *bodyTracker
+	once    sync.Once
+}
+
+func (t *trackedBody) Close() error {
+	t.once.Do(func() {
+		t.tracker.mu.Lock()
+		t.tracker.open--
+		t.tracker.mu.Unlock()
+	})
+	return t.ReadCloser.Close()
+}
+
+func TestPaginationClosesEachPageBody(t *testing.T) {
+	m := newMockGitHub(t)
+	var pages []interface{}
+	for i := 1; i <= 5; i++ {
+		pages = append(pages, []GitHubRepo{{Name: fmt.Sprint("repo", i), FullName: fmt.Sprint("octo/repo", i)}})
+	}
+	m.servePages("/users/octo/repos", pages...)
+	m.servePages("/repos/octo/app/commits",
+		[]GitHubCommit{{SHA: "sha1"}},
+		[]GitHubCommit{{SHA: "sha2"}},
+		[]GitHubCommit{{SHA: "sha3"}},
+	)
+
+	tracker := &bodyTracker{}
+	g := m.service()
+	g.Transport = tracker
+
+	repos, err := g.fetchUserRepos(context.Background(), "octo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 5 {
+		t.Errorf("got %d repos, want one from each of the 5 pages", len(repos))
+	}
+	commits, err := g.fetchRepoCommits(context.Background(), "octo/app", "octo", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 3 {
+		t.Errorf("got %d commits, want one from each of the 3 pages", len(commits))
+	}
+
+	if tracker.maxOpen != 0 {
+		t.Errorf("%d response bodies were still open when the next page was requested", tracker.maxOpen)
+	}
+	if tracker.open != 0 {
+		t.Errorf("%d response bodies were never closed", tracker.open)
+	}
+}
+
+func TestDoRequestGivesUpOnExhaustedRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// No X-RateLimit-Reset, so there is no reset to wait for
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	g := &GitHubService{APIBase: server.URL, MaxRetries: 1, MaxRateLimitWait: time.Minute}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/user", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := g.doRequest(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected an error once the retries are used up")
+	}
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("got error %v, want a RateLimitError", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2 (the first attempt and one retry)", got)
+	}
+}
+
+func TestPushEventsDoNotDoubleCountWalkedCommits(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	m := newMockGitHub(t)
+	m.serveJSON("/users/octo/repos", []GitHubRepo{{Name: "app", FullName: "octo/app", PushedAt: now}})
+	m.serveJSON("/repos/octo/app/commits", []GitHubCommit{
+		{SHA: "sha1", Commit: GitHubCommitData{Author: GitHubCommitAuthor{Date: now.Add(-time.Hour)}}},
+		{SHA: "sha2", Commit: GitHubCommitData{Author: GitHubCommitAuthor{Date: now.Add(-time.Hour)}}},
+	})
+	m.serveJSON("/repos/octo/app/pulls", []GitHubPullRequest{})
+	m.serveJSON("/repos/octo/app/issues", []GitHubIssue{})
+
+	// The push to the walked repository repeats its listed commits and adds
+	// one the listing doesn't have, which is dropped too since the listing is
+	// authoritative. The push to other/lib is the only record of its commit.
+	pushed := func(repo string, shas ...string) GitHubEvent {
+		var commits []interface{}
+		for _, sha := range shas {
+			commits = append(commits, map[string]interface{}{"sha": sha, "distinct": true})
+		}
+		return GitHubEvent{ID: repo, Type: "PushEvent", Repo: GitHubRepo{Name: repo}, CreatedAt: now, Public: true,
+			Payload: map[string]interface{}{"commits": commits}}
+	}
+	m.serveJSON("/users/octo/events", []GitHubEvent{
+		pushed("octo/app", "sha1", "sha2", "sha3"),
+		pushed("other/lib", "sha4"),
+	})
+
+	fetched, err := m.service().FetchUserActivity(context.Background(), "octo", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"commit octo/app sha1", "commit octo/app sha2", "commit other/lib sha4"}
+	if got := activityKeys(fetched.Activities); !slices.Equal(got, want) {
+		t.Errorf("got activities %v, want %v", got, want)
+	}
+	total := 0
+	for _, activity := range fetched.Activities {
+		total += activity.Count
+	}
+	if total != 3 {
+		t.Errorf("got %d commits in total, want 3", total)
+	}
+}
+
+func TestRepositoryNamesAreCanonical(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	m := newMockGitHub(t)
+	m.serveJSON("/users/octo/repos", []GitHubRepo{{Name: "app", FullName: "octo/app", PushedAt: now}})
+	m.serveJSON("/orgs/acme/repos", []GitHubRepo{{Name: "Tool", FullName: "Acme/Tool", PushedAt: now}})
+	for _, repo := range []string{"octo/app", "Acme/Tool"} {
+		m.serveJSON("/repos/"+repo+"/commits", []GitHubCommit{
+			{SHA: repo + "-sha", Commit: GitHubCommitData{Author: GitHubCommitAuthor{Date: now.Add(-time.Hour)}}},
+		})
+		m.serveJSON("/repos/"+repo+"/pulls", []GitHubPullRequest{})
+		m.serveJSON("/repos/"+repo+"/issues", []GitHubIssue{})
+	}
+
+	// Event payloads don't necessarily spell names the way full_name does
+	m.serveJSON("/users/octo/events", []GitHubEvent{
+		{ID: "1", Type: "IssuesEvent", Repo: GitHubRepo{Name: "acme/tool"}, CreatedAt: now, Public: true,
+			Payload: map[string]interface{}{"issue": map[string]interface{}{"number": 9}}},
+		{ID: "2", Type: "ForkEvent", Repo: GitHubRepo{Name: "OCTO/APP"}, CreatedAt: now, Public: true},
+	})
+
+	g := m.service()
+	g.Orgs = []string{"acme"}
+	fetched, err := g.FetchUserActivity(context.Background(), "octo", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"commit Acme/Tool Acme/Tool-sha",
+		"commit octo/app octo/app-sha",
+		"fork octo/app 2",
+		"issue Acme/Tool issue-9",
+	}
+	if got := activityKeys(fetched.Activities); !slices.Equal(got, want) {
+		t.Errorf("got activities %v, want %v", got, want)
+	}
+}
+
+func TestDoPaginatedStopConditions(t *testing.T) {
+	repos := func(n int) []GitHubRepo {
+		page := make([]GitHubRepo, n)
+		for i := range page {
+			page[i] = GitHubRepo{FullName: fmt.Sprint("octo/repo", i)}
+		}
+		return page
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		want     int
+		requests int
+	}{
+		{
+			name: "empty page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("[]"))
+			},
+			want:     0,
+			requests: 1,
+		},
+		{
+			name: "empty page despite a next link",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next"`, r.Host, r.URL.Path))
+				w.Write([]byte("[]"))
+			},
+			want:     0,
+			requests: 1,
+		},
+		{
+			name: "short page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(repos(3))
+			},
+			want:     3,
+			requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockGitHub(t)
+			m.handle("/users/octo/repos", tt.handler)
+
+			got, err := m.service().fetchUserRepos(context.Background(), "octo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %d repos, want %d", len(got), tt.want)
+			}
+			if n := m.requests("/users/octo/repos"); n != tt.requests {
+				t.Errorf("made %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+
+	// Pages served from the disk cache have no Link header, so a full page
+	// means there is another one and a short page is the last
+	t.Run("cached pages", func(t *testing.T) {
+		m := newMockGitHub(t)
+		m.servePages("/users/octo/repos", repos(perPage), repos(1))
+		g := m.service()
+		g.DiskCache = newDiskCache(t.TempDir(), time.Hour)
+
+		for run := 1; run <= 2; run++ {
+			got, err := g.fetchUserRepos(context.Background(), "octo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != perPage+1 {
+				t.Errorf("run %d got %d repos, want %d", run, len(got), perPage+1)
+			}
+		}
+		if n := m.requests("/users/octo/repos"); n != 2 {
+			t.Errorf("made %d requests, want 2 with the second run served from the cache", n)
+		}
+	})
+}
+
+func TestFetchRepoCommitsEmptyRepository(t *testing.T) {
+	m := newMockGitHub(t)
+	m.serveStatus("/repos/octo/empty/commits", http.StatusConflict)
+
+	// A 409 on a later page ends the listing with the pages before it
+	m.handle("/repos/octo/app/commits", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		query := testPageParam.ReplaceAllString(r.URL.RawQuery, "${1}page=2")
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, m.URL, r.URL.Path, query))
+		json.NewEncoder(w).Encode([]GitHubCommit{{SHA: "sha1"}})
+	})
+
+	g := m.service()
+	commits, err := g.fetchRepoCommits(context.Background(), "octo/empty", "octo", time.Time{})
+	if err != nil {
+		t.Fatalf("empty repository: %v", err)
+	}
+	if len(commits) != 0 {
+		t.Errorf("empty repository: got %d commits, want none", len(commits))
+	}
+
+	commits, err = g.fetchRepoCommits(context.Background(), "octo/app", "octo", time.Time{})
+	if err != nil {
+		t.Fatalf("409 on page 2: %v", err)
+	}
+	if got := activityKeys(commits); !slices.Equal(got, []string{"commit octo/app sha1"}) {
+		t.Errorf("409 on page 2: got %v, want the first page's commit", got)
+	}
+	if n := m.requests("/repos/octo/app/commits"); n != 2 {
+		t.Errorf("409 on page 2: made %d requests, want 2", n)
+	}
+}
+
+func TestPaginationFollowsLinkHeader(t *testing.T) {
+	full := make([]GitHubRepo, perPage)
+	for i := range full {
+		full[i] = GitHubRepo{FullName: fmt.Sprint("octo/repo", i)}
+	}
+
+	// Three pages chained by rel="next"; the last one is full, which only the
+	// missing next link reveals as the end
+	m := newMockGitHub(t)
+	m.servePages("/users/octo/repos", full[:1], full[:2], full)
+
+	repos, err := m.service().fetchUserRepos(context.Background(), "octo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1 + 2 + perPage; len(repos) != want {
+		t.Errorf("got %d repos, want %d", len(repos), want)
+	}
+	if n := m.requests("/users/octo/repos"); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{``, ``},
+		{`<https://api.github.com/user/repos?page=3>; rel="last"`, ``},
+		{
+			`<https://api.github.com/user/repos?page=1>; rel="prev", <https://api.github.com/user/repos?page=3>; rel="next", <https://api.github.com/user/repos?page=5>; rel="last"`,
+			`https://api.github.com/user/repos?page=3`,
+		},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.link != "" {
+			header.Set("Link", tt.link)
+		}
+		if got := nextPageURL(header); got != tt.want {
+			t.Errorf("nextPageURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// contributionsQuery pulls a user's commit, pull request and issue
+// contributions between from and to, plus their repositories. Each part is
+// skipped once its pages are exhausted, and the cursors pick up where the
+// previous page of pull requests, issues and repositories ended.
+const contributionsQuery = `
+query($login: String!, $from: DateTime!, $to: DateTime!,
+      $commits: Boolean!, $pullRequests: Boolean!, $pullRequestCursor: String,
+      $issues: Boolean!, $issueCursor: String, $repos: Boolean!, $repoCursor: String) {
+  user(login: $login) {
+    contributionsCollection(from: $from, to: $to) {
+      commitContributionsByRepository(maxRepositories: 100) @include(if: $commits) {
+        repository { nameWithOwner url isPrivate isFork pushedAt }
+        contributions(first: 100, orderBy: {direction: DESC}) {
+          pageInfo { hasNextPage }
+          nodes { occurredAt commitCount }
+        }
+      }
+      pullRequestContributions(first: 100, after: $pullRequestCursor, orderBy: {direction: DESC}) @include(if: $pullRequests) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          occurredAt
+          pullRequest { number title state url repository { nameWithOwner isPrivate isFork pushedAt } }
+        }
+      }
+      issueContributions(first: 100, after: $issueCursor, orderBy: {direction: DESC}) @include(if: $issues) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          occurredAt
+          issue { number title state url repository { nameWithOwner isPrivate isFork pushedAt } }
+        }
+      }
+    }
+    repositories(first: 100, after: $repoCursor, orderBy: {field: PUSHED_AT, direction: DESC}) @include(if: $repos) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name nameWithOwner url stargazerCount forkCount description isPrivate isFork pushedAt
+        primaryLanguage { name }
+        repositoryTopics(first: 20) { nodes { topic { name } } }
+      }
+    }
+  }
+}`
+
+// graphQLPageSize is the most items a GraphQL connection returns per page,
+// and the most repositories commitContributionsByRepository returns at all
+const graphQLPageSize = 100
+
+// contributionsWindowDays is the longest span fetched per contributionsQuery.
+// A repository has at most one commit contribution per day, so within it no
+// repository has more than one page of them; those connections can't be
+// paged through for a single repository.
+const contributionsWindowDays = 90
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphQLRepoRef struct {
+	NameWithOwner string    `json:"nameWithOwner"`
+	URL           string    `json:"url"`
+	IsPrivate     bool      `json:"isPrivate"`
+	IsFork        bool      `json:"isFork"`
+	PushedAt      time.Time `json:"pushedAt"`
+}
+
+// repo returns the fields of the repository that the repository filters look at
+func (ref graphQLRepoRef) repo() GitHubRepo {
+	return GitHubRepo{FullName: ref.NameWithOwner, Private: ref.IsPrivate, Fork: ref.IsFork, PushedAt: ref.PushedAt}
+}
+
+type contributionsUser struct {
+	ContributionsCollection struct {
+		CommitContributionsByRepository []struct {
+			Repository    graphQLRepoRef `json:"repository"`
+			Contributions struct {
+				PageInfo graphQLPageInfo `json:"pageInfo"`
+				Nodes    []struct {
+					OccurredAt  time.Time `json:"occurredAt"`
+					CommitCount int       `json:"commitCount"`
+				} `json:"nodes"`
+			} `json:"contributions"`
+		} `json:"commitContributionsByRepository"`
+		PullRequestContributions struct {
+			PageInfo graphQLPageInfo `json:"pageInfo"`
+			Nodes    []struct {
+				OccurredAt  time.Time `json:"occurredAt"`
+				PullRequest struct {
+					Number     int            `json:"number"`
+					Title      string         `json:"title"`
+					State      string         `json:"state"`
+					URL        string         `json:"url"`
+					Repository graphQLRepoRef `json:"repository"`
+				} `json:"pullRequest"`
+			} `json:"nodes"`
+		} `json:"pullRequestContributions"`
+		IssueContributions struct {
+			PageInfo graphQLPageInfo `json:"pageInfo"`
+			Nodes    []struct {
+				OccurredAt time.Time `json:"occurredAt"`
+				Issue      struct {
+					Number     int            `json:"number"`
+					Title      string         `json:"title"`
+					State      string         `json:"state"`
+					URL        string         `json:"url"`
+					Repository graphQLRepoRef `json:"repository"`
+				} `json:"issue"`
+			} `json:"nodes"`
+		} `json:"issueContributions"`
+	} `json:"contributionsCollection"`
+	Repositories struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			Name            string    `json:"name"`
+			NameWithOwner   string    `json:"nameWithOwner"`
+			URL             string    `json:"url"`
+			StargazerCount  int       `json:"stargazerCount"`
+			ForkCount       int       `json:"forkCount"`
+			Description     string    `json:"description"`
+			IsPrivate       bool      `json:"isPrivate"`
+			IsFork          bool      `json:"isFork"`
+			PushedAt        time.Time `json:"pushedAt"`
+			PrimaryLanguage *struct {
+				Name string `json:"name"`
+			} `json:"primaryLanguage"`
+			RepositoryTopics struct {
+				Nodes []struct {
+					Topic struct {
+						Name string `json:"name"`
+					} `json:"topic"`
+				} `json:"nodes"`
+			} `json:"repositoryTopics"`
+		} `json:"nodes"`
+	} `json:"repositories"`
+}
+
+type contributionsResponse struct {
+	Data struct {
+		User *contributionsUser `json:"user"`
+	} `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+// nextCursor returns the cursor of the page after this one, or nil once the
+// connection is exhausted
+func (info graphQLPageInfo) nextCursor() *string {
+	if !info.HasNextPage || info.EndCursor == "" {
+		return nil
+	}
+	return &info.EndCursor
+}
+
+// FetchUserActivityGraphQL collects the user's activity through the GraphQL
+// contributionsCollection API. Commits are reported as daily counts per
+// repository rather than individual commits, so it needs a few requests per
+// contributionsWindowDays instead of several per repository. Every connection
+// is paged through; where that isn't possible an error is returned, so the
+// caller falls back to REST rather than storing truncated counts. The
+// repositories are held to the same filters and GITHUB_MAX_REPOS cap as the
+// REST walk.
+func (g *GitHubService) FetchUserActivityGraphQL(ctx context.Context, username string, progress ProgressFunc) (UserActivity, error) {
+	since := g.HistoryStart()
+	result := UserActivity{Since: since, DailyCommits: true}
+
+	// Every repository the activity is in, for the repository filters
+	var active []GitHubRepo
+	seen := make(map[string]bool)
+	addRepo := func(ref graphQLRepoRef) {
+		if !seen[ref.NameWithOwner] {
+			seen[ref.NameWithOwner] = true
+			active = append(active, ref.repo())
+		}
+	}
+
+	// The user's repositories are listed alongside the first window
+	var owned []GitHubRepo
+	repos := true
+	var repoCursor *string
+
+	now := time.Now().UTC()
+	for from := since.UTC(); from.Before(now); from = from.AddDate(0, 0, contributionsWindowDays) {
+		to := from.AddDate(0, 0, contributionsWindowDays)
+		if to.After(now) {
+			to = now
+		}
+		progress.report(fmt.Sprintf("fetching contributions from %s via GraphQL", from.Format("2006-01-02")))
+
+		commits, pullRequests, issues := true, true, true
+		var pullRequestCursor, issueCursor *string
+		for commits || pullRequests || issues || repos {
+			user, err := g.queryContributions(ctx, username, map[string]interface{}{
+				"login":             username,
+				"from":              from.Format(time.RFC3339),
+				"to":                to.Format(time.RFC3339),
+				"commits":           commits,
+				"pullRequests":      pullRequests,
+				"pullRequestCursor": pullRequestCursor,
+				"issues":            issues,
+				"issueCursor":       issueCursor,
+				"repos":             repos,
+				"repoCursor":        repoCursor,
+			})
+			if err != nil {
+				return UserActivity{}, err
+			}
+			contributions := user.ContributionsCollection
+
+			if commits {
+				byRepos := contributions.CommitContributionsByRepository
+				if len(byRepos) >= graphQLPageSize {
+					return UserActivity{}, fmt.Errorf("commits to %d or more repositories between %s and %s, more than GraphQL returns",
+						len(byRepos), from.Format("2006-01-02"), to.Format("2006-01-02"))
+				}
+				for _, byRepo := range byRepos {
+					repoName := byRepo.Repository.NameWithOwner
+					if byRepo.Contributions.PageInfo.HasNextPage {
+						return UserActivity{}, fmt.Errorf("more than %d days of commits to %s between %s and %s",
+							graphQLPageSize, repoName, from.Format("2006-01-02"), to.Format("2006-01-02"))
+					}
+					addRepo(byRepo.Repository)
+					for _, node := range byRepo.Contributions.Nodes {
+						day := node.OccurredAt.UTC().Format("2006-01-02")
+						result.Activities = append(result.Activities, GitHubActivity{
+							Date:         node.OccurredAt,
+							Repository:   repoName,
+							ActivityType: "commit",
+							Count:        node.CommitCount,
+							URL:          byRepo.Repository.URL + "/commits",
+							GitHubID:     dailyCommitPrefix + day,
+							Private:      byRepo.Repository.IsPrivate,
+						})
+					}
+				}
+				commits = false
+			}
+
+			if pullRequests {
+				for _, node := range contributions.PullRequestContributions.Nodes {
+					pr := node.PullRequest
+					addRepo(pr.Repository)
+					result.Activities = append(result.Activities, GitHubActivity{
+						Date:         node.OccurredAt,
+						Repository:   pr.Repository.NameWithOwner,
+						ActivityType: "pull_request",
+						Count:        1,
+						URL:          pr.URL,
+						GitHubID:     fmt.Sprintf("pr-%d", pr.Number),
+						Number:       pr.Number,
+						Title:        pr.Title,
+						State:        strings.ToLower(pr.State),
+						Private:      pr.Repository.IsPrivate,
+					})
+				}
+				pullRequestCursor = contributions.PullRequestContributions.PageInfo.nextCursor()
+				pullRequests = pullRequestCursor != nil
+			}
+
+			if issues {
+				for _, node := range contributions.IssueContributions.Nodes {
+					issue := node.Issue
+					addRepo(issue.Repository)
+					result.Activities = append(result.Activities, GitHubActivity{
+						Date:         node.OccurredAt,
+						Repository:   issue.Repository.NameWithOwner,
+						ActivityType: "issue",
+						Count:        1,
+						URL:          issue.URL,
+						GitHubID:     fmt.Sprintf("issue-%d", issue.Number),
+						Number:       issue.Number,
+						Title:        issue.Title,
+						State:        strings.ToLower(issue.State),
+						Private:      issue.Repository.IsPrivate,
+					})
+				}
+				issueCursor = contributions.IssueContributions.PageInfo.nextCursor()
+				issues = issueCursor != nil
+			}
+
+			if repos {
+				for _, repo := range user.Repositories.Nodes {
+					language := ""
+					if repo.PrimaryLanguage != nil {
+						language = repo.PrimaryLanguage.Name
+					}
+					var topics []string
+					for _, node := range repo.RepositoryTopics.Nodes {
+						topics = append(topics, node.Topic.Name)
+					}
+					owned = append(owned, GitHubRepo{
+						Name:            repo.Name,
+						FullName:        repo.NameWithOwner,
+						URL:             g.APIBase + "/repos/" + repo.NameWithOwner,
+						HTMLURL:         repo.URL,
+						StargazersCount: repo.StargazerCount,
+						ForksCount:      repo.ForkCount,
+						Language:        language,
+						Description:     repo.Description,
+						Topics:          topics,
+						Private:         repo.IsPrivate,
+						Fork:            repo.IsFork,
+						PushedAt:        repo.PushedAt,
+					})
+				}
+				repoCursor = user.Repositories.PageInfo.nextCursor()
+				repos = repoCursor != nil
+			}
+		}
+	}
+	result.Repos = g.filterRepos(owned)
+
+	allowed := make(map[string]bool)
+	for _, repo := range g.capRepos(ctx, g.filterRepos(active)) {
+		allowed[repo.FullName] = true
+	}
+	var kept []GitHubActivity
+	for _, activity := range result.Activities {
+		if allowed[activity.Repository] {
+			kept = append(kept, activity)
+		}
+	}
+	result.Activities = kept
+	return result, nil
+}
+
+// queryContributions runs contributionsQuery with the given variables and
+// returns the user it found
+func (g *GitHubService) queryContributions(ctx context.Context, username string, variables map[string]interface{}) (*contributionsUser, error) {
+	var resp contributionsResponse
+	if err := g.postGraphQL(ctx, contributionsQuery, variables, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		var messages []string
+		for _, e := range resp.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+	if resp.Data.User == nil {
+		return nil, fmt.Errorf("GitHub user %s not found", username)
+	}
+	return resp.Data.User, nil
+}
+
+// postGraphQL sends a query to the GraphQL API and decodes the response into v
+func (g *GitHubService) postGraphQL(ctx context.Context, query string, variables map[string]interface{}, v interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.graphQLURL(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", githubAPIVersion)
+
+	resp, err := g.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode GraphQL response: %w", err)
+	}
+	return nil
+}
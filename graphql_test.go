@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// graphQLServer answers every GraphQL query with the given response body
+func graphQLServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// graphQLVariables are the variables of a contributionsQuery request
+type graphQLVariables struct {
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	Commits           bool      `json:"commits"`
+	PullRequests      bool      `json:"pullRequests"`
+	PullRequestCursor *string   `json:"pullRequestCursor"`
+	Repos             bool      `json:"repos"`
+}
+
+// pagedGraphQLServer answers each GraphQL query with the body respond returns
+// for its variables, and records the variables of every request
+func pagedGraphQLServer(t *testing.T, respond func(graphQLVariables) string) (*httptest.Server, func() []graphQLVariables) {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []graphQLVariables
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables graphQLVariables `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		seen = append(seen, req.Variables)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(respond(req.Variables)))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []graphQLVariables {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(seen)
+	}
+}
+
+const twoRepoContributions = `{"data": {"user": {
+	"contributionsCollection": {
+		"commitContributionsByRepository": [
+			{"repository": {"nameWithOwner": "octo/kept", "url": "https://github.com/octo/kept"},
+			 "contributions": {"nodes": [{"occurredAt": "2024-03-01T08:00:00Z", "commitCount": 2}]}},
+			{"repository": {"nameWithOwner": "octo/skipped", "url": "https://github.com/octo/skipped"},
+			 "contributions": {"nodes": [{"occurredAt": "2024-03-01T08:00:00Z", "commitCount": 5}]}}
+		],
+		"pullRequestContributions": {"nodes": [
+			{"occurredAt": "2024-03-02T10:00:00Z", "pullRequest": {"number": 7, "title": "Skipped PR", "state": "OPEN",
+			 "url": "https://github.com/octo/skipped/pull/7", "repository": {"nameWithOwner": "octo/skipped"}}}
+		]},
+		"issueContributions": {"nodes": []}
+	},
+	"repositories": {"nodes": [
+		{"name": "kept", "nameWithOwner": "octo/kept", "url": "https://github.com/octo/kept"},
+		{"name": "skipped", "nameWithOwner": "octo/skipped", "url": "https://github.com/octo/skipped"}
+	]}
+}}}`
+
+func TestFetchUserActivityGraphQLAppliesRepoExclude(t *testing.T) {
+	server := graphQLServer(t, twoRepoContributions)
+	g := &GitHubService{
+		Token:       "token",
+		APIBase:     server.URL + "/api/v3",
+		UseGraphQL:  true,
+		RepoExclude: []string{"skipped"},
+	}
+
+	fetched, err := g.FetchUserActivity(context.Background(), "octo", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, activity := range fetched.Activities {
+		if activity.Repository != "octo/kept" {
+			t.Errorf("got activity in excluded repository %s", activity.Repository)
+		}
+	}
+	if len(fetched.Activities) != 1 {
+		t.Errorf("got %d activities, want 1", len(fetched.Activities))
+	}
+	if len(fetched.Repos) != 1 || fetched.Repos[0].FullName != "octo/kept" {
+		t.Errorf("got repos %+v, want only octo/kept", fetched.Repos)
+	}
+}
+
+func TestFetchUserActivityGraphQLSkipsForksAndCapsRepos(t *testing.T) {
+	server := graphQLServer(t, `{"data": {"user": {
+		"contributionsCollection": {
+			"commitContributionsByRepository": [
+				{"repository": {"nameWithOwner": "octo/old", "pushedAt": "2024-01-01T00:00:00Z"},
+				 "contributions": {"nodes": [{"occurredAt": "2024-03-01T08:00:00Z", "commitCount": 1}]}},
+				{"repository": {"nameWithOwner": "octo/new", "pushedAt": "2024-03-01T00:00:00Z"},
+				 "contributions": {"nodes": [{"occurredAt": "2024-03-01T08:00:00Z", "commitCount": 1}]}},
+				{"repository": {"nameWithOwner": "octo/fork", "isFork": true, "pushedAt": "2024-03-05T00:00:00Z"},
+				 "contributions": {"nodes": [{"occurredAt": "2024-03-01T08:00:00Z", "commitCount": 1}]}}
+			],
+			"pullRequestContributions": {"nodes": []},
+			"issueContributions": {"nodes": []}
+		},
+		"repositories": {"nodes": []}
+	}}}`)
+	g := &GitHubService{Token: "token", APIBase: server.URL + "/api/v3", MaxRepos: 1}
+
+	fetched, err := g.FetchUserActivityGraphQL(context.Background(), "octo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fetched.DailyCommits {
+		t.Error("GraphQL results should be flagged as daily commit counts")
+	}
+	if len(fetched.Activities) != 1 || fetched.Activities[0].Repository != "octo/new" {
+		t.Errorf("got activities %+v, want only the most recently pushed non-fork octo/new", fetched.Activities)
+	}
+}
+
+func TestFetchUserActivityGraphQLPagesThroughContributions(t *testing.T) {
+	pullRequest := func(number int) string {
+		return `{"occurredAt": "2024-03-02T10:00:00Z", "pullRequest": {"number": ` + strconv.Itoa(number) +
+			`, "title": "PR", "state": "MERGED", "url": "https://github.com/octo/app/pull/` + strconv.Itoa(number) +
+			`", "repository": {"nameWithOwner": "octo/app"}}}`
+	}
+	server, requests := pagedGraphQLServer(t, func(v graphQLVariables) string {
+		page := `{"pageInfo": {"hasNextPage": true, "endCursor": "second"}, "nodes": [` + pullRequest(1) + `]}`
+		if v.PullRequestCursor != nil && *v.PullRequestCursor == "second" {
+			page = `{"pageInfo": {"hasNextPage": false, "endCursor": "end"}, "nodes": [` + pullRequest(2) + `]}`
+		}
+		return `{"data": {"user": {"contributionsCollection": {
+			"commitContributionsByRepository": [],
+			"pullRequestContributions": ` + page + `,
+			"issueContributions": {"nodes": []}
+		}, "repositories": {"nodes": []}}}}`
+	})
+	g := &GitHubService{Token: "token", APIBase: server.URL + "/api/v3", HistoryMonths: 6}
+
+	fetched, err := g.FetchUserActivityGraphQL(context.Background(), "octo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each window of at most contributionsWindowDays fetches both pages of
+	// pull requests, and its commits and the repositories only once
+	var numbers []int
+	for _, activity := range fetched.Activities {
+		numbers = append(numbers, activity.Number)
+	}
+	windows := 0
+	var end time.Time
+	for i, v := range requests() {
+		if v.Commits {
+			windows++
+			if !end.IsZero() && !v.From.Equal(end) {
+				t.Errorf("request %d starts at %s, want the previous window's end %s", i, v.From, end)
+			}
+			end = v.To
+		}
+		if v.To.Sub(v.From) > contributionsWindowDays*24*time.Hour {
+			t.Errorf("request %d spans %s to %s, longer than %d days", i, v.From, v.To, contributionsWindowDays)
+		}
+		if v.Repos != (i == 0) {
+			t.Errorf("request %d lists repositories: %v, want only the first", i, v.Repos)
+		}
+	}
+	if windows < 2 {
+		t.Fatalf("got %d windows over six months, want them split", windows)
+	}
+	if len(requests()) != 2*windows {
+		t.Errorf("got %d requests for %d windows, want two each", len(requests()), windows)
+	}
+	if len(numbers) != 2*windows || numbers[0] != 1 || numbers[1] != 2 {
+		t.Errorf("got pull requests %v, want both pages of every window", numbers)
+	}
+}
+
+func TestFetchUserActivityGraphQLRejectsTruncatedCommits(t *testing.T) {
+	server := graphQLServer(t, `{"data": {"user": {
+		"contributionsCollection": {
+			"commitContributionsByRepository": [
+				{"repository": {"nameWithOwner": "octo/busy"},
+				 "contributions": {"pageInfo": {"hasNextPage": true, "endCursor": "more"},
+				                   "nodes": [{"occurredAt": "2024-03-01T08:00:00Z", "commitCount": 1}]}}
+			],
+			"pullRequestContributions": {"nodes": []},
+			"issueContributions": {"nodes": []}
+		},
+		"repositories": {"nodes": []}
+	}}}`)
+	g := &GitHubService{Token: "token", APIBase: server.URL + "/api/v3"}
+
+	_, err := g.FetchUserActivityGraphQL(context.Background(), "octo", nil)
+	if err == nil || !strings.Contains(err.Error(), "octo/busy") {
+		t.Errorf("got error %v, want one naming the repository whose commits were cut off", err)
+	}
+}
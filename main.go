@@ -1,24 +1,66 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// version identifies the build, such as in the GitHub User-Agent. Release
+// builds set it with -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+// githubUsernamePattern matches GitHub's username rules: alphanumeric characters
+// separated by single hyphens, with no leading or trailing hyphen.
+var githubUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+
+// validGitHubUsername reports whether name is an acceptable GitHub username
+func validGitHubUsername(name string) bool {
+	return len(name) <= 39 && githubUsernamePattern.MatchString(name)
+}
+
 type App struct {
+	Config        *Config
 	DB            *sql.DB
 	GitHubService *GitHubService
+	// APIToken, when set, must be sent as a bearer token to trigger a refresh
+	APIToken string
+	// ProtectReads extends the APIToken requirement to the read-only API endpoints
+	ProtectReads bool
+	// Metrics collects the operational metrics served on /metrics; nil disables them
+	Metrics *Metrics
+	// SearchFTS is set when the FTS5 search index is available; otherwise
+	// /api/search falls back to LIKE matching
+	SearchFTS bool
+
+	// refreshMu is held for the duration of a refresh so overlapping
+	// refreshes don't race on the same rows
+	refreshMu sync.Mutex
 }
 
+// errRefreshInProgress is returned when a refresh is requested while another is running
+var errRefreshInProgress = errors.New("refresh already in progress")
+
 type GitHubActivity struct {
 	ID           int       `json:"id"`
 	Date         time.Time `json:"date"`
@@ -26,7 +68,13 @@ type GitHubActivity struct {
 	ActivityType string    `json:"activity_type"`
 	Count        int       `json:"count"`
 	URL          string    `json:"url"`
-	GitHubID     string    `json:"github_id"` // Unique identifier from GitHub (SHA for commits, number for PRs/issues)
+	GitHubID     string    `json:"github_id"`         // Unique identifier from GitHub (SHA for commits, number for PRs/issues)
+	Number       int       `json:"number,omitempty"`  // PR or issue number
+	Title        string    `json:"title,omitempty"`   // PR or issue title
+	State        string    `json:"state,omitempty"`   // open, closed or merged
+	Message      string    `json:"message,omitempty"` // Commit message
+	Private      bool      `json:"private,omitempty"` // Made in a private repository
+	Stale        bool      `json:"stale,omitempty"`   // The repository was deleted or renamed
 }
 
 type PRComment struct {
@@ -50,356 +98,1493 @@ type ProjectEntry struct {
 	URL            string      `json:"url"`
 }
 
+// RepoGroup holds a repository's commits bucketed by day, most recent first
+type RepoGroup struct {
+	Repository   string         `json:"repository"`
+	URL          string         `json:"url"`
+	LatestDate   time.Time      `json:"latest_date"`
+	TotalCommits int            `json:"total_commits"`
+	Buckets      []CommitBucket `json:"buckets"`
+}
+
+type CommitBucket struct {
+	Date  time.Time `json:"date"`
+	Count int       `json:"count"`
+	// Messages are the first lines of the bucket's most recent commit messages
+	Messages []string       `json:"messages,omitempty"`
+	Commits  []CommitDetail `json:"commits"`
+}
+
+type CommitDetail struct {
+	SHA     string `json:"sha"`
+	Message string `json:"message"`
+	URL     string `json:"url"`
+}
+
+type RepoSummary struct {
+	Repository        string    `json:"repository"`
+	Description       string    `json:"description"`
+	Topics            []string  `json:"topics"`
+	TotalCommits      int       `json:"total_commits"`
+	TotalPullRequests int       `json:"total_pull_requests"`
+	TotalIssues       int       `json:"total_issues"`
+	LatestDate        time.Time `json:"latest_date"`
+	URL               string    `json:"url"`
+}
+
 type BlogEntry struct {
 	Repository   string           `json:"repository"`
 	LatestDate   time.Time        `json:"latest_date"`
 	URL          string           `json:"url"`
 	PullRequests []GitHubActivity `json:"pull_requests"`
 	Issues       []GitHubActivity `json:"issues"`
+	Reviews      []GitHubActivity `json:"reviews"`
 	Commits      []GitHubActivity `json:"commits"`
 }
 
-// Handler for /api/commits: returns last 6 months of commits grouped by repo, ordered by most recent commit per repo
+// bucketStart returns the start of the day, ISO week (Monday) or calendar month
+// containing t
+func bucketStart(t time.Time, groupBy string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch groupBy {
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	default:
+		return day
+	}
+}
+
+// Handler for /api/commits: returns commits from the history window grouped by repo, ordered by most recent commit per repo.
+// The window can be overridden with from/to query parameters (YYYY-MM-DD), and
+// group_by=week|month rolls the daily buckets up into ISO weeks or months.
 func (app *App) getCommitsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get pagination parameters
-	page := 1
-	limit := 100
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	switch groupBy {
+	case "":
+		groupBy = "day"
+	case "day", "week", "month":
+	default:
+		writeJSONError(w, http.StatusBadRequest, "invalid group_by: "+groupBy+", expected day, week or month")
+		return
+	}
+
+	// Individual commits listed per day; the day's count still covers them all
+	commitsPerDay := 10
+	if perDayStr := r.URL.Query().Get("commits_per_day"); perDayStr != "" {
+		if n, err := strconv.Atoi(perDayStr); err == nil && n >= 0 && n <= 100 {
+			commitsPerDay = n
+		}
+	}
+
+	// Representative commit messages per day, unless messages=false
+	messagesPerDay := 3
+	if messagesStr := r.URL.Query().Get("messages"); messagesStr != "" {
+		include, err := strconv.ParseBool(messagesStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid messages: "+messagesStr+", expected true or false")
+			return
+		}
+		if !include {
+			messagesPerDay = 0
+		}
+	}
+	if perDayStr := r.URL.Query().Get("messages_per_day"); perDayStr != "" && messagesPerDay > 0 {
+		if n, err := strconv.Atoi(perDayStr); err == nil && n >= 0 && n <= 100 {
+			messagesPerDay = n
+		}
+	}
+
+	// Optional date range, defaulting to the configured history window up to today
+	today := app.GitHubService.Today()
+	from := today.AddDate(0, -app.GitHubService.HistoryMonths, 0)
+	to := today
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, today.Location())
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid from date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, today.Location())
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid to date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+
+	if from.After(to) {
+		writeJSONError(w, http.StatusBadRequest, "from date must not be after to date")
+		return
+	}
+
+	if app.notModified(w, r) {
+		return
+	}
+
+	// The range is inclusive of the whole "to" day, in the configured time zone
+	fromDate := formatActivityDate(from)
+	toDate := formatActivityDate(to.AddDate(0, 0, 1))
+
+	// First, get total count of repositories with commits
+	var totalRepos int
+	err = app.DB.QueryRow(`
+		SELECT COUNT(DISTINCT repository) 
+		FROM github_activity 
+		WHERE activity_type = 'commit' AND date >= ? AND date < ?
+	`, fromDate, toDate).Scan(&totalRepos)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Get all commits data first, then group and paginate
+	rows, err := app.DB.Query(`
+		SELECT repository, date, COALESCE(url, '') as url, count, COALESCE(github_id, '') as github_id, message
+		FROM github_activity
+		WHERE activity_type = 'commit' AND date >= ? AND date < ?
+		ORDER BY date DESC, repository
+	`, fromDate, toDate)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	// Group commits by repo, then by day, week or month. Rows arrive newest
+	// first, so each repo's buckets are already in descending order.
+	repoGroups := make(map[string]*RepoGroup)
+	for rows.Next() {
+		var repo, dateStr, url, githubID, message string
+		var count int
+		err := rows.Scan(&repo, &dateStr, &url, &count, &githubID, &message)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		date := app.parseActivityDate(dateStr)
+		day := bucketStart(date, groupBy)
+
+		group, ok := repoGroups[repo]
+		if !ok {
+			group = &RepoGroup{Repository: repo, LatestDate: date, URL: app.GitHubService.WebBase + "/" + repo}
+			repoGroups[repo] = group
+		}
+
+		if n := len(group.Buckets); n == 0 || !group.Buckets[n-1].Date.Equal(day) {
+			group.Buckets = append(group.Buckets, CommitBucket{Date: day})
+		}
+		bucket := &group.Buckets[len(group.Buckets)-1]
+		bucket.Count += count
+		group.TotalCommits += count
+
+		if len(bucket.Commits) < commitsPerDay {
+			bucket.Commits = append(bucket.Commits, CommitDetail{SHA: githubID, Message: message, URL: url})
+		}
+		summary, _, _ := strings.Cut(message, "\n")
+		if summary = strings.TrimSpace(summary); summary != "" && len(bucket.Messages) < messagesPerDay {
+			bucket.Messages = append(bucket.Messages, summary)
+		}
+	}
+
+	// Prepare ordered list of repos by most recent commit
+	var allRepoGroups []RepoGroup
+	for _, group := range repoGroups {
+		allRepoGroups = append(allRepoGroups, *group)
+	}
+
+	// Sort repos by most recent commit date (descending), then by name so
+	// repos with equal dates keep the same order across pages
+	sort.Slice(allRepoGroups, func(i, j int) bool {
+		a, b := allRepoGroups[i], allRepoGroups[j]
+		if !a.LatestDate.Equal(b.LatestDate) {
+			return a.LatestDate.After(b.LatestDate)
+		}
+		return a.Repository < b.Repository
+	})
+
+	// Apply pagination
+	start, end, pagination := paginate(len(allRepoGroups), page, limit)
+	setPageLinks(w, r, page, end < len(allRepoGroups))
+
+	// Prepare response with pagination metadata
+	response := map[string]interface{}{
+		"data":       allRepoGroups[start:end],
+		"pagination": pagination,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CommitInfo describes a single stored commit
+type CommitInfo struct {
+	SHA        string    `json:"sha"`
+	Repository string    `json:"repository"`
+	Date       time.Time `json:"date"`
+	Message    string    `json:"message"`
+	URL        string    `json:"html_url"`
+}
+
+// Handler for /api/commits/{sha}: returns a single commit by its full SHA, or
+// 404 if it isn't stored. A commit found in several repositories (such as a
+// fork) is reported from the one where it was seen most recently.
+func (app *App) getCommitHandler(w http.ResponseWriter, r *http.Request) {
+	sha := strings.TrimPrefix(r.URL.Path, "/api/commits/")
+	if sha == "" || strings.Contains(sha, "/") {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	commit := CommitInfo{SHA: sha}
+	var dateStr string
+	err := app.DB.QueryRow(`
+		SELECT repository, date, message, COALESCE(url, '') as url
+		FROM github_activity
+		WHERE github_id = ? AND activity_type = 'commit'
+		ORDER BY date DESC
+		LIMIT 1
+	`, sha).Scan(&commit.Repository, &dateStr, &commit.Message, &commit.URL)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "commit not found: "+sha)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	commit.Date = app.parseActivityDate(dateStr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commit)
+}
+
+// Handler for /api/issues: returns issues from the history window grouped by repo, ordered by most recent issue per repo.
+// state=open|closed limits the issues listed; the open and closed totals cover the whole window either way.
+func (app *App) getIssuesHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since := app.GitHubService.HistoryStart().Format("2006-01-02")
+
+	state := r.URL.Query().Get("state")
+	if state != "" && state != "open" && state != "closed" {
+		writeJSONError(w, http.StatusBadRequest, "invalid state: "+state+", expected open or closed")
+		return
+	}
+
+	counts := map[string]int{"open": 0, "closed": 0}
+	countRows, err := app.DB.Query(`
+		SELECT state, COUNT(*)
+		FROM github_activity
+		WHERE activity_type = 'issue' AND date >= ? AND state IN ('open', 'closed')
+		GROUP BY state
+	`, since)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for countRows.Next() {
+		var issueState string
+		var n int
+		if err := countRows.Scan(&issueState, &n); err != nil {
+			countRows.Close()
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		counts[issueState] = n
+	}
+	countRows.Close()
+	if err := countRows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := `
+		SELECT repository, date, COALESCE(url, '') as url, count, COALESCE(github_id, '') as github_id,
+		       number, title, state
+		FROM github_activity
+		WHERE activity_type = 'issue' AND date >= ?`
+	args := []interface{}{since}
+	if state != "" {
+		query += " AND state = ?"
+		args = append(args, state)
+	}
+	query += " ORDER BY date DESC, repository"
+
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	// Group issues by repo
+	repoIssues := make(map[string][]GitHubActivity)
+	for rows.Next() {
+		activity := GitHubActivity{ActivityType: "issue"}
+		var dateStr string
+		err := rows.Scan(&activity.Repository, &dateStr, &activity.URL, &activity.Count, &activity.GitHubID,
+			&activity.Number, &activity.Title, &activity.State)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		activity.Date = app.parseActivityDate(dateStr)
+		repoIssues[activity.Repository] = append(repoIssues[activity.Repository], activity)
+	}
+
+	type IssueGroup struct {
+		Repository string           `json:"repository"`
+		Issues     []GitHubActivity `json:"issues"`
+		LatestDate time.Time        `json:"latest_date"`
+	}
+	var allIssueGroups []IssueGroup
+	for repo, issues := range repoIssues {
+		allIssueGroups = append(allIssueGroups, IssueGroup{
+			Repository: repo,
+			Issues:     issues,
+			LatestDate: issues[0].Date,
+		})
+	}
+
+	// Sort repos by most recent issue date (descending), then by name so
+	// repos with equal dates keep the same order across pages
+	sort.Slice(allIssueGroups, func(i, j int) bool {
+		a, b := allIssueGroups[i], allIssueGroups[j]
+		if !a.LatestDate.Equal(b.LatestDate) {
+			return a.LatestDate.After(b.LatestDate)
+		}
+		return a.Repository < b.Repository
+	})
+
+	start, end, pagination := paginate(len(allIssueGroups), page, limit)
+	setPageLinks(w, r, page, end < len(allIssueGroups))
+
+	response := map[string]interface{}{
+		"data":       allIssueGroups[start:end],
+		"pagination": pagination,
+		"counts":     counts,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// maxPageLimit is the largest page size the paginated endpoints return
+const maxPageLimit = 100
+
+// parsePagination reads the page and limit query parameters, defaulting to the
+// first page of maxPageLimit items. A limit above maxPageLimit is clamped, and
+// the applied value is reported back in the pagination metadata; anything that
+// isn't a positive integer is an error.
+func parsePagination(r *http.Request) (page, limit int, err error) {
+	page = 1
+	limit = maxPageLimit
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		p, err := strconv.Atoi(pageStr)
+		if err != nil || p < 1 {
+			return 0, 0, fmt.Errorf("invalid page: %s, expected a positive integer", pageStr)
+		}
+		page = p
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 {
+			return 0, 0, fmt.Errorf("invalid limit: %s, expected a positive integer", limitStr)
+		}
+		limit = min(l, maxPageLimit)
+	}
+
+	return page, limit, nil
+}
+
+// parseCursorLimit reads the limit of an endpoint paged by cursor, rejecting
+// page rather than silently returning the first page again
+func parseCursorLimit(r *http.Request) (int, error) {
+	if r.URL.Query().Has("page") {
+		return 0, errors.New("page is not supported here, pass next_cursor as cursor instead")
+	}
+	_, limit, err := parsePagination(r)
+	return limit, err
+}
+
+// paginate returns the slice bounds of the requested page within total items,
+// along with the pagination metadata returned to clients
+func paginate(total, page, limit int) (start, end int, pagination map[string]interface{}) {
+	start = (page - 1) * limit
+	end = start + limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+
+	pagination = map[string]interface{}{
+		"page":        page,
+		"limit":       limit,
+		"max_limit":   maxPageLimit,
+		"total":       total,
+		"total_pages": (total + limit - 1) / limit,
+		"has_next":    end < total,
+		"has_prev":    page > 1,
+	}
+	return start, end, pagination
+}
+
+// setPageLinks sets a Link header pointing at the previous and next pages of a
+// paginated response, when they exist
+func setPageLinks(w http.ResponseWriter, r *http.Request, page int, hasNext bool) {
+	var links []string
+	if page > 1 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageLink(r, "page", strconv.Itoa(page-1))))
+	}
+	if hasNext {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageLink(r, "page", strconv.Itoa(page+1))))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink returns the request's path and query with one query parameter
+// replaced, keeping the others
+func pageLink(r *http.Request, key, value string) string {
+	query := r.URL.Query()
+	query.Set(key, value)
+	return r.URL.Path + "?" + query.Encode()
+}
+
+func (app *App) initDB() error {
+	var err error
+	// WAL lets the API keep reading while a refresh transaction writes.
+	// Writers still take turns: transactions claim the write lock up front
+	// (_txlock=immediate) and wait up to the busy timeout for it, rather than
+	// failing with "database is locked".
+	app.DB, err = sql.Open("sqlite3", "file:./activity.db?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return err
+	}
+
+	// SQLite serializes writes anyway, so a small pool of connections is
+	// enough for concurrent readers
+	app.DB.SetMaxOpenConns(10)
+	app.DB.SetMaxIdleConns(10)
+	app.DB.SetConnMaxLifetime(5 * time.Minute)
+
+	if err := app.migrate(); err != nil {
+		return err
+	}
+	return app.setupSearchIndex()
+}
+
+// formatActivityDate formats a timestamp the way activity dates are stored:
+// RFC 3339 in UTC, so that they sort and compare correctly as strings
+func formatActivityDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseActivityDate parses a stored activity timestamp into the configured
+// time zone, accepting the bare YYYY-MM-DD dates written by older versions
+func (app *App) parseActivityDate(value string) time.Time {
+	loc := app.GitHubService.location()
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(loc)
+	}
+	t, _ := time.ParseInLocation("2006-01-02", value, loc)
+	return t
+}
+
+// dbResponseCache stores GitHub conditional request state in the github_cache table
+type dbResponseCache struct {
+	db *sql.DB
+}
+
+func (c *dbResponseCache) Get(key string) (string, []byte, bool) {
+	var etag string
+	var body []byte
+	err := c.db.QueryRow("SELECT etag, body FROM github_cache WHERE key = ?", key).Scan(&etag, &body)
+	if err != nil {
+		return "", nil, false
+	}
+	return etag, body, true
+}
+
+func (c *dbResponseCache) Put(key, etag string, body []byte) error {
+	_, err := c.db.Exec(`
+		INSERT OR REPLACE INTO github_cache (key, etag, body, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, key, etag, body)
+	return err
+}
+
+// renameLegacyRepoRows moves rows that older versions stored as
+// username/name for repositories owned by an organization to the canonical
+// owner/name. Rows that already exist under the canonical name are dropped.
+// Names the user also owns a repository under are left alone.
+func renameLegacyRepoRows(ctx context.Context, tx *sql.Tx, username string, repos []GitHubRepo) error {
+	owned := make(map[string]bool)
+	for _, repo := range repos {
+		if owner, _, _ := strings.Cut(repo.FullName, "/"); strings.EqualFold(owner, username) {
+			owned[strings.ToLower(repo.Name)] = true
+		}
+	}
+
+	for _, repo := range repos {
+		owner, name, _ := strings.Cut(repo.FullName, "/")
+		if strings.EqualFold(owner, username) || owned[strings.ToLower(name)] {
+			continue
+		}
+
+		legacy := username + "/" + name
+		res, err := tx.Exec("UPDATE OR IGNORE github_activity SET repository = ? WHERE repository = ?", repo.FullName, legacy)
+		if err != nil {
+			return fmt.Errorf("failed to rename %s rows: %w", legacy, err)
+		}
+		if _, err := tx.Exec("DELETE FROM github_activity WHERE repository = ?", legacy); err != nil {
+			return fmt.Errorf("failed to remove duplicate %s rows: %w", legacy, err)
+		}
+		if renamed, _ := res.RowsAffected(); renamed > 0 {
+			slog.InfoContext(ctx, "renamed legacy repository rows", "from", legacy, "to", repo.FullName, "rows", renamed)
+		}
+	}
+	return nil
+}
+
+// previewRefresh counts how many of the fetched activities are already stored
+// and how many a refresh would insert, without writing anything. Activities
+// without a GitHub ID are always inserted, so they count as new.
+func (app *App) previewRefresh(activities []GitHubActivity) (RefreshResult, error) {
+	result := RefreshResult{Fetched: len(activities)}
+
+	stmt, err := app.DB.Prepare(`
+		SELECT COUNT(*) FROM github_activity
+		WHERE repository = ? AND activity_type = ? AND github_id = ?
+	`)
+	if err != nil {
+		return result, fmt.Errorf("failed to prepare activity lookup: %w", err)
+	}
+	defer stmt.Close()
+
+	seen := make(map[string]bool)
+	for _, activity := range activities {
+		if activity.GitHubID == "" {
+			result.Inserted++
+			continue
+		}
+
+		key := activity.Repository + "#" + activity.ActivityType + "#" + activity.GitHubID
+		if seen[key] {
+			// A duplicate within the batch updates the row its first copy wrote
+			result.Existing++
+			continue
+		}
+		seen[key] = true
+
+		var count int
+		err := stmt.QueryRow(activity.Repository, activity.ActivityType, activity.GitHubID).Scan(&count)
+		if err != nil {
+			return result, fmt.Errorf("failed to look up activity: %w", err)
+		}
+		if count > 0 {
+			result.Existing++
+		} else {
+			result.Inserted++
+		}
+	}
+
+	return result, nil
+}
+
+// getMetadata reads a value from the metadata table, reporting whether it was set
+func (app *App) getMetadata(key string) (string, bool, error) {
+	var value string
+	err := app.DB.QueryRow("SELECT value FROM metadata WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// setMetadata stores a value in the metadata table, replacing any previous value
+func (app *App) setMetadata(key, value string) error {
+	_, err := app.DB.Exec("INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)", key, value)
+	return err
+}
+
+// Handler for /: renders the index page. The activity type its activity list
+// starts with comes from the default_view query parameter, falling back to the
+// DEFAULT_VIEW setting.
+func (app *App) indexHandler(w http.ResponseWriter, r *http.Request) {
+	defaultView := app.Config.DefaultView
+	if view := r.URL.Query().Get("default_view"); view != "" {
+		if view != "all" && !validActivityType(view) {
+			writeJSONError(w, http.StatusBadRequest, "invalid default_view: "+view)
+			return
+		}
+		defaultView = view
+	}
+
+	tmpl, err := template.ParseFiles("./static/index.html")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, map[string]string{"DefaultView": defaultView}); err != nil {
+		slog.ErrorContext(r.Context(), "failed to render index page", "error", err)
+	}
+}
+
+// activityTypes lists every activity type stored in github_activity
+var activityTypes = []string{"commit", "pull_request", "issue", "review", "repository", "fork", "star", "activity"}
+
+func validActivityType(activityType string) bool {
+	for _, t := range activityTypes {
+		if t == activityType {
+			return true
+		}
+	}
+	return false
+}
+
+// activityFilter builds a WHERE clause from the optional type and repository
+// query parameters, returning an error if the type is not a known activity type
+func activityFilter(r *http.Request) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	if activityType := r.URL.Query().Get("type"); activityType != "" {
+		if !validActivityType(activityType) {
+			return "", nil, fmt.Errorf("unknown activity type %q, expected one of: %s", activityType, strings.Join(activityTypes, ", "))
+		}
+		conditions = append(conditions, "activity_type = ?")
+		args = append(args, activityType)
+	}
+
+	if repository := r.URL.Query().Get("repository"); repository != "" {
+		conditions = append(conditions, "repository = ?")
+		args = append(args, repository)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// encodeActivityCursor makes an opaque cursor pointing just past an activity
+func encodeActivityCursor(date string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(date + "|" + strconv.Itoa(id)))
+}
+
+// decodeActivityCursor reads the date and id of the last activity a client saw
+func decodeActivityCursor(cursor string) (date string, id int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, errors.New("invalid cursor")
+	}
+	date, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return "", 0, errors.New("invalid cursor")
+	}
+	if id, err = strconv.Atoi(idStr); err != nil {
+		return "", 0, errors.New("invalid cursor")
+	}
+	return date, id, nil
+}
+
+// Handler for /api/activity: returns the most recent activities (up to limit,
+// default 100), optionally filtered by type and repository. Pages are chained
+// with the returned next_cursor rather than offsets, so rows inserted by a
+// refresh in between don't shift or repeat items.
+func (app *App) getActivityHandler(w http.ResponseWriter, r *http.Request) {
+	where, args, err := activityFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, err := parseCursorLimit(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fields, err := parseFields(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		date, id, err := decodeActivityCursor(cursor)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if where == "" {
+			where = "WHERE "
+		} else {
+			where += " AND "
+		}
+		where += "(date < ? OR (date = ? AND id < ?))"
+		args = append(args, date, date, id)
+	}
+
+	if app.notModified(w, r) {
+		return
+	}
+
+	rows, err := app.DB.Query(`
+		SELECT id, date, repository, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, state, private, stale
+		FROM github_activity
+		`+where+`
+		ORDER BY date DESC, id DESC
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	activities := []GitHubActivity{}
+	var lastDate string
+	for rows.Next() {
+		var activity GitHubActivity
+		var dateStr string
+		err := rows.Scan(&activity.ID, &dateStr, &activity.Repository, &activity.ActivityType, &activity.Count, &activity.URL, &activity.GitHubID,
+			&activity.Number, &activity.Title, &activity.State, &activity.Private, &activity.Stale)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		activity.Date = app.parseActivityDate(dateStr)
+		activities = append(activities, activity)
+		lastDate = dateStr
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// A full page may have more after it; a short one is the end
+	var nextCursor *string
+	if len(activities) == limit {
+		cursor := encodeActivityCursor(lastDate, activities[len(activities)-1].ID)
+		nextCursor = &cursor
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, pageLink(r, "cursor", cursor)))
+	}
+
+	var data interface{} = activities
+	if fields != nil {
+		if data, err = selectFields(activities, fields); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        data,
+		"next_cursor": nextCursor,
+	})
+}
+
+// activityFields lists the keys of the objects returned by /api/activity
+var activityFields = []string{"id", "date", "repository", "activity_type", "count", "url", "github_id", "number", "title", "state", "private", "stale"}
+
+// parseFields reads the optional comma-separated fields query parameter,
+// returning nil when it is absent and an error naming any unknown field
+func parseFields(r *http.Request) ([]string, error) {
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+	fields := splitList(value)
+	for _, field := range fields {
+		if !slices.Contains(activityFields, field) {
+			return nil, fmt.Errorf("unknown field %q, expected one of: %s", field, strings.Join(activityFields, ", "))
+		}
+	}
+	return fields, nil
+}
+
+// selectFields reduces each activity to the requested keys. Keys left out of
+// an activity's JSON, such as a commit's number, stay absent.
+func selectFields(activities []GitHubActivity, fields []string) ([]map[string]json.RawMessage, error) {
+	selected := make([]map[string]json.RawMessage, 0, len(activities))
+	for _, activity := range activities {
+		encoded, err := json.Marshal(activity)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &all); err != nil {
+			return nil, err
+		}
+		item := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				item[field] = value
+			}
+		}
+		selected = append(selected, item)
+	}
+	return selected, nil
+}
+
+// Handler for /api/activity/{id}: returns a single stored activity by its id,
+// or 404 if there is none
+func (app *App) getActivityItemHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/activity/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		writeJSONError(w, http.StatusBadRequest, "invalid activity id: "+idStr)
+		return
+	}
+
+	activity := GitHubActivity{ID: id}
+	var dateStr string
+	err = app.DB.QueryRow(`
+		SELECT date, repository, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, state, message, private, stale
+		FROM github_activity
+		WHERE id = ?
+	`, id).Scan(&dateStr, &activity.Repository, &activity.ActivityType, &activity.Count, &activity.URL, &activity.GitHubID,
+		&activity.Number, &activity.Title, &activity.State, &activity.Message, &activity.Private, &activity.Stale)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "activity not found: "+idStr)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	activity.Date = app.parseActivityDate(dateStr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activity)
+}
+
+// Handler for /api/since: returns the activities dated after ts (RFC3339,
+// default 24 hours ago), oldest first and up to limit (default 100), so a
+// client can append what is new without reloading everything. Further pages
+// are chained with the returned next_cursor, like /api/activity.
+func (app *App) getSinceHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseCursorLimit(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if ts := r.URL.Query().Get("ts"); ts != "" {
+		parsed, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid ts: "+ts+", expected an RFC3339 timestamp such as 2024-01-01T00:00:00Z")
+			return
+		}
+		since = parsed
+	}
+	since = since.UTC()
+	after := since.Format(time.RFC3339Nano)
+
+	// A cursor resumes just past the last activity of the previous page,
+	// taking the rows sharing its date that come after it by id
+	afterCondition := "julianday(date) > julianday(?)"
+	args := []interface{}{after}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		date, id, err := decodeActivityCursor(cursor)
+		if err == nil {
+			since, err = time.Parse(time.RFC3339, date)
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		since = since.UTC()
+		after = date
+		afterCondition = "(julianday(date) > julianday(?) OR (julianday(date) = julianday(?) AND id > ?))"
+		args = []interface{}{date, date, id}
+	}
+
+	// Dates carry the offset they were stored with (see TIMEZONE), so they are
+	// compared as instants; the plain string bound a day earlier, which no
+	// offset can span, lets the date index narrow the scan first
+	args = append([]interface{}{since.AddDate(0, 0, -1).Format("2006-01-02")}, args...)
+	rows, err := app.DB.Query(`
+		SELECT id, date, repository, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, state, message, private, stale
+		FROM github_activity
+		WHERE date >= ? AND `+afterCondition+`
+		ORDER BY julianday(date), id
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	activities := []GitHubActivity{}
+	var lastDate string
+	for rows.Next() {
+		var activity GitHubActivity
+		var dateStr string
+		err := rows.Scan(&activity.ID, &dateStr, &activity.Repository, &activity.ActivityType, &activity.Count,
+			&activity.URL, &activity.GitHubID, &activity.Number, &activity.Title, &activity.State, &activity.Message, &activity.Private, &activity.Stale)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		activity.Date = app.parseActivityDate(dateStr)
+		activities = append(activities, activity)
+		lastDate = dateStr
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// A full page may have more after it; a short one is the end
+	var nextCursor *string
+	if len(activities) == limit {
+		cursor := encodeActivityCursor(lastDate, activities[len(activities)-1].ID)
+		nextCursor = &cursor
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, pageLink(r, "cursor", cursor)))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":       after,
+		"data":        activities,
+		"next_cursor": nextCursor,
+	})
+}
+
+// errorResponse is the body of every API error
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+// writeJSONError writes an error message and its status code as a JSON body
+// with the given status code
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message, Code: status})
+}
+
+func (app *App) refreshActivityHandler(w http.ResponseWriter, r *http.Request) {
+	// An optional username parameter overrides the configured default
+	username := r.URL.Query().Get("username")
+	if username != "" && !validGitHubUsername(username) {
+		writeJSONError(w, http.StatusBadRequest, "invalid GitHub username: "+username)
+		return
+	}
+
+	// dry_run fetches from GitHub and reports what would change without writing
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid dry_run value: "+v)
+			return
+		}
+	}
+
+	// This will fetch data from GitHub API and store in database
+	// Cancelled if the client disconnects, aborting in-flight GitHub calls
+	result, err := app.fetchGitHubActivity(r.Context(), RefreshOptions{Username: username, DryRun: dryRun})
+	if errors.Is(err, errRefreshInProgress) {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to refresh activity: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if dryRun {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "dry_run",
+			"fetched":  result.Fetched,
+			"new":      result.Inserted,
+			"existing": result.Existing,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// refreshStreamHandler runs a refresh and streams its progress to the client
+// as Server-Sent Events, finishing with a "done" or "error" event
+func (app *App) refreshStreamHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("username")
+	if username != "" && !validGitHubUsername(username) {
+		writeJSONError(w, http.StatusBadRequest, "invalid GitHub username: "+username)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	send := func(event, data string) {
+		if event != "" {
+			fmt.Fprintf(w, "event: %s\n", event)
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	result, err := app.fetchGitHubActivity(r.Context(), RefreshOptions{
+		Username: username,
+		Progress: func(message string) { send("", message) },
+	})
+	if err != nil {
+		send("error", err.Error())
+		return
+	}
+
+	send("done", fmt.Sprintf("done: %d fetched, %d inserted", result.Fetched, result.Inserted))
+}
+
+// RefreshOptions controls a single refresh
+type RefreshOptions struct {
+	// Username overrides GITHUB_USERNAME when set
+	Username string
+	// DryRun fetches from GitHub but only reports what would be written
+	DryRun bool
+	// Progress, if set, receives progress messages as the refresh proceeds
+	Progress ProgressFunc
+}
+
+// RefreshResult summarizes what a refresh stored in the database
+type RefreshResult struct {
+	Fetched  int // activities returned by GitHub
+	Inserted int // activities that were not already stored
+	Existing int // activities already stored (only counted for dry runs)
+}
+
+// fetchGitHubActivity fetches the user's activity and upserts it into the
+// database, reporting progress through the optional callback
+func (app *App) fetchGitHubActivity(ctx context.Context, opts RefreshOptions) (result RefreshResult, err error) {
+	username, progress := opts.Username, opts.Progress
+
+	if !app.refreshMu.TryLock() {
+		return result, errRefreshInProgress
+	}
+	defer app.refreshMu.Unlock()
+
+	if !opts.DryRun {
+		defer func() { app.Metrics.observeRefresh(result, err) }()
+	}
 
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+	// Fall back to the configured GitHub username
+	if username == "" {
+		username = app.Config.Username
 	}
 
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
+	watermarks, err := app.commitWatermarks()
+	if err != nil {
+		return result, err
+	}
+
+	fetched, err := app.GitHubService.FetchUserActivity(ctx, username, watermarks, progress)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch GitHub activity: %w", err)
 	}
+	activities := fetched.Activities
 
-	sixMonthsAgo := time.Now().AddDate(0, -6, 0).Format("2006-01-02")
+	if opts.DryRun {
+		return app.previewRefresh(activities)
+	}
 
-	// First, get total count of repositories with commits
-	var totalRepos int
-	err := app.DB.QueryRow(`
-		SELECT COUNT(DISTINCT repository) 
-		FROM github_activity 
-		WHERE activity_type = 'commit' AND date >= ?
-	`, sixMonthsAgo).Scan(&totalRepos)
+	// Upsert activity data in a single transaction so a failed refresh leaves
+	// the table untouched
+	tx, err := app.DB.Begin()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Get all commits data first, then group and paginate
-	rows, err := app.DB.Query(`
-		SELECT repository, date, url, count, activity_type, COALESCE(github_id, '') as github_id
-		FROM github_activity
-		WHERE activity_type = 'commit' AND date >= ?
-		ORDER BY date DESC, repository
-	`, sixMonthsAgo)
+	if err := renameLegacyRepoRows(ctx, tx, username, fetched.Repos); err != nil {
+		return result, err
+	}
+
+	inserted, err := storeUserActivity(tx, fetched)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return result, err
 	}
-	defer rows.Close()
+	result.Fetched = len(activities)
+	result.Inserted = inserted
 
-	// Group commits by repo
-	repoCommits := make(map[string][]GitHubActivity)
-	for rows.Next() {
-		var repo, dateStr, url, activityType, githubID string
-		var count int
-		err := rows.Scan(&repo, &dateStr, &url, &count, &activityType, &githubID)
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit activity: %w", err)
+	}
+	progress.report("inserted %d rows", result.Inserted)
+
+	// Fetch PR comments for repositories with recent activity
+	progress.report("fetching pull request comments")
+	prComments, err := app.GitHubService.FetchPRComments(ctx, username)
+	if err != nil {
+		// Log error but don't fail the whole refresh
+		slog.WarnContext(ctx, "failed to fetch PR comments", "error", err)
+	} else {
+		// Clear old PR comments
+		_, err = app.DB.Exec("DELETE FROM pr_comments WHERE created_at < ?", app.GitHubService.HistoryStart().Format(time.RFC3339))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			slog.WarnContext(ctx, "failed to clear old PR comments", "error", err)
 		}
-		date, _ := time.Parse("2006-01-02", dateStr)
-		activity := GitHubActivity{
-			Date:         date,
-			Repository:   repo,
-			ActivityType: activityType,
-			Count:        count,
-			URL:          url,
-			GitHubID:     githubID,
+
+		// Insert new PR comments
+		for _, comment := range prComments {
+			_, err := app.DB.Exec(`
+				INSERT OR REPLACE INTO pr_comments 
+				(repository, pr_number, pr_title, author, body, created_at, pr_url, comment_url)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, comment.Repository, comment.PRNumber, comment.PRTitle, comment.Author,
+				comment.Body, comment.CreatedAt.Format(time.RFC3339), comment.PRURL, comment.CommentURL)
+			if err != nil {
+				slog.WarnContext(ctx, "failed to insert PR comment", "repo", comment.Repository, "pr", comment.PRNumber, "error", err)
+			}
 		}
-		repoCommits[repo] = append(repoCommits[repo], activity)
 	}
 
-	// Prepare ordered list of repos by most recent commit
-	type RepoGroup struct {
-		Repository string           `json:"repository"`
-		Commits    []GitHubActivity `json:"commits"`
-		LatestDate time.Time        `json:"latest_date"`
-	}
-	var allRepoGroups []RepoGroup
-	for repo, commits := range repoCommits {
-		if len(commits) > 0 {
-			latest := commits[0].Date
-			allRepoGroups = append(allRepoGroups, RepoGroup{
-				Repository: repo,
-				Commits:    commits,
-				LatestDate: latest,
-			})
-		}
+	if err := app.setMetadata("last_refresh_at", time.Now().UTC().Format(time.RFC3339)); err != nil {
+		slog.WarnContext(ctx, "failed to record refresh time", "error", err)
 	}
 
-	// Sort repos by most recent commit date (descending)
-	sort.Slice(allRepoGroups, func(i, j int) bool {
-		return allRepoGroups[i].LatestDate.After(allRepoGroups[j].LatestDate)
-	})
+	return result, nil
+}
 
-	// Apply pagination
-	start := (page - 1) * limit
-	end := start + limit
-	if start > len(allRepoGroups) {
-		start = len(allRepoGroups)
+// githubRepoNamePattern matches an owner/name repository name
+var githubRepoNamePattern = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*/[A-Za-z0-9._-]+$`)
+
+// Handler for POST /api/refresh/repo?name=owner/repo: fetches and stores the
+// activity of a single repository, reporting how many rows were fetched and how
+// many of them are new
+func (app *App) refreshRepoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
 	}
-	if end > len(allRepoGroups) {
-		end = len(allRepoGroups)
+
+	name := r.URL.Query().Get("name")
+	if !githubRepoNamePattern.MatchString(name) || strings.HasSuffix(name, "/.") || strings.HasSuffix(name, "/..") {
+		writeJSONError(w, http.StatusBadRequest, "invalid repository name: "+name+", expected owner/repo")
+		return
 	}
 
-	paginatedRepoGroups := allRepoGroups[start:end]
+	if !app.refreshMu.TryLock() {
+		writeJSONError(w, http.StatusConflict, errRefreshInProgress.Error())
+		return
+	}
+	defer app.refreshMu.Unlock()
 
-	// Prepare response with pagination metadata
-	response := map[string]interface{}{
-		"data": paginatedRepoGroups,
-		"pagination": map[string]interface{}{
-			"page":        page,
-			"limit":       limit,
-			"total":       len(allRepoGroups),
-			"total_pages": (len(allRepoGroups) + limit - 1) / limit,
-			"has_next":    end < len(allRepoGroups),
-			"has_prev":    page > 1,
-		},
+	result, err := app.refreshRepo(r.Context(), name)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		writeJSONError(w, http.StatusNotFound, "repository not found: "+name)
+		return
+	}
+	if errors.Is(err, errRepoExcluded) {
+		writeJSONError(w, http.StatusBadRequest, name+": "+err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to refresh repository: "+err.Error())
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"repository": name,
+		"fetched":    result.Fetched,
+		"new":        result.Inserted,
+	})
 }
 
-func (app *App) initDB() error {
-	var err error
-	app.DB, err = sql.Open("sqlite3", "./activity.db")
+// refreshRepo fetches a single repository's activity and upserts it, as
+// fetchGitHubActivity does for all of them, recording the refresh in the
+// metrics. The caller holds refreshMu.
+func (app *App) refreshRepo(ctx context.Context, name string) (result RefreshResult, err error) {
+	defer func() { app.Metrics.observeRefresh(result, err) }()
+
+	watermarks, err := app.commitWatermarks()
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	// Enable connection pooling
-	app.DB.SetMaxOpenConns(25)
-	app.DB.SetMaxIdleConns(5)
-	app.DB.SetConnMaxLifetime(5 * time.Minute)
+	fetched, err := app.GitHubService.FetchRepoActivity(ctx, name, app.Config.Username, watermarks[name])
+	if err != nil {
+		return result, err
+	}
+	result.Fetched = len(fetched.Activities)
 
-	// Create tables without the unique index initially
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS github_activity (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		date TEXT NOT NULL,
-		repository TEXT NOT NULL,
-		activity_type TEXT NOT NULL,
-		count INTEGER DEFAULT 1,
-		url TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_date ON github_activity(date);
-	CREATE INDEX IF NOT EXISTS idx_repo ON github_activity(repository);
-
-	CREATE TABLE IF NOT EXISTS pr_comments (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		repository TEXT NOT NULL,
-		pr_number INTEGER NOT NULL,
-		pr_title TEXT NOT NULL,
-		author TEXT NOT NULL,
-		body TEXT,
-		created_at TEXT NOT NULL,
-		pr_url TEXT,
-		comment_url TEXT,
-		fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_pr_comments_repo ON pr_comments(repository);
-	CREATE INDEX IF NOT EXISTS idx_pr_comments_created ON pr_comments(created_at);
-	`
-
-	_, err = app.DB.Exec(createTableSQL)
+	tx, err := app.DB.Begin()
 	if err != nil {
-		return err
+		return result, err
 	}
+	defer tx.Rollback()
 
-	// Migration: Add github_id column if it doesn't exist
-	var columnExists bool
-	err = app.DB.QueryRow(`
-		SELECT COUNT(*) > 0 
-		FROM pragma_table_info('github_activity') 
-		WHERE name = 'github_id'
-	`).Scan(&columnExists)
+	inserted, err := storeUserActivity(tx, fetched)
 	if err != nil {
-		return fmt.Errorf("failed to check for github_id column: %w", err)
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
 	}
+	result.Inserted = inserted
+	return result, nil
+}
 
-	if !columnExists {
-		// Add the github_id column to existing table
-		_, err = app.DB.Exec(`ALTER TABLE github_activity ADD COLUMN github_id TEXT NOT NULL DEFAULT ''`)
-		if err != nil {
-			return fmt.Errorf("failed to add github_id column: %w", err)
-		}
+// storeUserActivity upserts fetched activity, along with its repositories'
+// metadata and commit watermarks, and returns how many activity rows are new.
+// Rows already stored have their details (such as a pull request's state)
+// updated. The only rows deleted are commits stored the other way (daily
+// counts versus one row per SHA), which replaceCommitRows drops.
+func storeUserActivity(tx *sql.Tx, fetched UserActivity) (int, error) {
+	if err := replaceCommitRows(tx, fetched); err != nil {
+		return 0, err
+	}
 
-		// Clean up duplicates before creating the unique index
-		// Keep only the most recent entry for each unique combination
-		_, err = app.DB.Exec(`
-			DELETE FROM github_activity
-			WHERE id NOT IN (
-				SELECT MIN(id)
-				FROM github_activity
-				GROUP BY date, repository, activity_type, github_id
-			)
-		`)
-		if err != nil {
-			return fmt.Errorf("failed to clean up duplicate entries: %w", err)
-		}
+	var before int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM github_activity").Scan(&before); err != nil {
+		return 0, fmt.Errorf("failed to count activity: %w", err)
 	}
 
-	// Check if the unique index already exists
-	var indexExists bool
-	err = app.DB.QueryRow(`
-		SELECT COUNT(*) > 0
-		FROM sqlite_master
-		WHERE type = 'index' AND name = 'idx_unique_activity'
-	`).Scan(&indexExists)
+	stmt, err := tx.Prepare(`
+		INSERT INTO github_activity (date, repository, activity_type, count, url, github_id, number, title, state, message, private)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(repository, activity_type, github_id) WHERE github_id != '' DO UPDATE SET
+			date = excluded.date,
+			count = excluded.count,
+			url = excluded.url,
+			number = excluded.number,
+			title = excluded.title,
+			state = excluded.state,
+			message = excluded.message,
+			private = excluded.private,
+			stale = 0
+	`)
 	if err != nil {
-		return fmt.Errorf("failed to check for unique index: %w", err)
+		return 0, fmt.Errorf("failed to prepare activity upsert: %w", err)
 	}
+	defer stmt.Close()
 
-	// Only create the index if it doesn't exist
-	if !indexExists {
-		// Clean up any duplicates that might exist before creating the index
-		_, err = app.DB.Exec(`
-			DELETE FROM github_activity
-			WHERE id NOT IN (
-				SELECT MIN(id)
-				FROM github_activity
-				GROUP BY date, repository, activity_type, github_id
-			)
-		`)
+	for _, activity := range fetched.Activities {
+		_, err := stmt.Exec(formatActivityDate(activity.Date), activity.Repository, activity.ActivityType, activity.Count,
+			activity.URL, activity.GitHubID, activity.Number, activity.Title, activity.State, activity.Message, activity.Private)
+		if err != nil {
+			return 0, fmt.Errorf("failed to upsert activity: %w", err)
+		}
+	}
+
+	// Record today's star and fork counts; a second refresh on the same day
+	// overwrites the earlier snapshot
+	today := time.Now().UTC().Format("2006-01-02")
+	for _, repo := range fetched.Repos {
+		_, err := tx.Exec(`
+			INSERT INTO repo_snapshots (repository, date, stars, forks)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(repository, date) DO UPDATE SET stars = excluded.stars, forks = excluded.forks
+		`, repo.FullName, today, repo.StargazersCount, repo.ForksCount)
 		if err != nil {
-			return fmt.Errorf("failed to clean up duplicate entries: %w", err)
+			return 0, fmt.Errorf("failed to store repository snapshot: %w", err)
 		}
 
-		// Now create the unique index
-		_, err = app.DB.Exec(`CREATE UNIQUE INDEX idx_unique_activity ON github_activity(date, repository, activity_type, github_id)`)
+		topics, err := json.Marshal(append([]string{}, repo.Topics...))
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode repository topics: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO repositories (repository, language, description, topics)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(repository) DO UPDATE SET language = excluded.language, description = excluded.description,
+				topics = excluded.topics, updated_at = CURRENT_TIMESTAMP
+		`, repo.FullName, repo.Language, repo.Description, string(topics))
 		if err != nil {
-			return fmt.Errorf("failed to create unique index: %w", err)
+			return 0, fmt.Errorf("failed to store repository: %w", err)
 		}
 	}
 
-	return nil
-}
+	// Keep the activity of repositories that disappeared, but flag it
+	for _, repository := range fetched.GoneRepos {
+		if _, err := tx.Exec("UPDATE github_activity SET stale = 1 WHERE repository = ?", repository); err != nil {
+			return 0, fmt.Errorf("failed to mark activity stale: %w", err)
+		}
+	}
 
-func (app *App) indexHandler(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "./static/index.html")
-}
+	// Advance each listed repository's watermark to its newest commit, so the
+	// next refresh only asks for commits from then on
+	for repository, date := range fetched.CommitWatermarks {
+		_, err := tx.Exec(`
+			INSERT INTO repositories (repository, last_commit_at)
+			VALUES (?, ?)
+			ON CONFLICT(repository) DO UPDATE SET last_commit_at = MAX(last_commit_at, excluded.last_commit_at)
+		`, repository, date.UTC().Format(time.RFC3339))
+		if err != nil {
+			return 0, fmt.Errorf("failed to store commit watermark: %w", err)
+		}
+	}
 
-func (app *App) getActivityHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query(`
-		SELECT id, date, repository, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id
-		FROM github_activity 
-		ORDER BY date DESC 
-		LIMIT 100
-	`)
+	// Upserts can change rows without adding any, so record when the activity
+	// last changed for activityETag
+	_, err = tx.Exec("INSERT OR REPLACE INTO metadata (key, value) VALUES ('activity_updated_at', ?)",
+		time.Now().UTC().Format(time.RFC3339Nano))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return 0, fmt.Errorf("failed to record activity update time: %w", err)
 	}
-	defer rows.Close()
 
-	var activities []GitHubActivity
-	for rows.Next() {
-		var activity GitHubActivity
-		var dateStr string
-		err := rows.Scan(&activity.ID, &dateStr, &activity.Repository, &activity.ActivityType, &activity.Count, &activity.URL, &activity.GitHubID)
+	var after int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM github_activity").Scan(&after); err != nil {
+		return 0, fmt.Errorf("failed to count activity: %w", err)
+	}
+	return after - before, nil
+}
+
+// dailyCommitPrefix starts the github_id of the per-day commit counts stored
+// by GraphQL refreshes; REST refreshes store commits by SHA
+const dailyCommitPrefix = "commits-"
+
+// replaceCommitRows deletes the commits in the fetched history window that
+// were stored by the other kind of refresh, so switching GITHUB_API or falling
+// back from GraphQL to REST doesn't count the same commits twice. A GraphQL
+// refresh replaces every per-SHA commit in the window and clears the commit
+// watermarks so the next REST refresh lists the whole window again. A REST
+// refresh replaces the daily counts of the repositories it listed commits for.
+func replaceCommitRows(tx *sql.Tx, fetched UserActivity) error {
+	since := formatActivityDate(fetched.Since)
+	if fetched.DailyCommits {
+		_, err := tx.Exec(`
+			DELETE FROM github_activity
+			WHERE activity_type = 'commit' AND github_id NOT LIKE ? AND julianday(date) >= julianday(?)
+		`, dailyCommitPrefix+"%", since)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return fmt.Errorf("failed to replace commits: %w", err)
 		}
-
-		activity.Date, _ = time.Parse("2006-01-02", dateStr)
-		activities = append(activities, activity)
+		if _, err := tx.Exec("UPDATE repositories SET last_commit_at = ''"); err != nil {
+			return fmt.Errorf("failed to reset commit watermarks: %w", err)
+		}
+		return nil
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(activities)
+	listed := make(map[string]bool)
+	for _, activity := range fetched.Activities {
+		if activity.ActivityType == "commit" && !listed[activity.Repository] {
+			listed[activity.Repository] = true
+			_, err := tx.Exec(`
+				DELETE FROM github_activity
+				WHERE repository = ? AND activity_type = 'commit' AND github_id LIKE ? AND julianday(date) >= julianday(?)
+			`, activity.Repository, dailyCommitPrefix+"%", since)
+			if err != nil {
+				return fmt.Errorf("failed to replace commits: %w", err)
+			}
+		}
+	}
+	return nil
 }
 
-func (app *App) refreshActivityHandler(w http.ResponseWriter, r *http.Request) {
-	// This will fetch data from GitHub API and store in database
-	err := app.fetchGitHubActivity()
+// commitWatermarks returns the newest commit date stored for each repository
+// by earlier refreshes
+func (app *App) commitWatermarks() (map[string]time.Time, error) {
+	rows, err := app.DB.Query("SELECT repository, last_commit_at FROM repositories WHERE last_commit_at != ''")
 	if err != nil {
-		http.Error(w, "Failed to refresh activity: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to read commit watermarks: %w", err)
 	}
+	defer rows.Close()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	watermarks := make(map[string]time.Time)
+	for rows.Next() {
+		var repository, value string
+		if err := rows.Scan(&repository, &value); err != nil {
+			return nil, fmt.Errorf("failed to read commit watermarks: %w", err)
+		}
+		if date, err := time.Parse(time.RFC3339, value); err == nil {
+			watermarks[repository] = date
+		}
+	}
+	return watermarks, rows.Err()
 }
 
-func (app *App) fetchGitHubActivity() error {
-	// Get GitHub username from environment or use default
-	username := os.Getenv("GITHUB_USERNAME")
-	if username == "" {
-		username = "kristofer" // Default username
+// activityCounts returns the number of stored activity rows of each type,
+// including types with none, plus their "total"
+func (app *App) activityCounts() (map[string]int, error) {
+	counts := map[string]int{"total": 0}
+	for _, activityType := range activityTypes {
+		counts[activityType] = 0
 	}
 
-	activities, err := app.GitHubService.FetchUserActivity(username)
+	rows, err := app.DB.Query("SELECT activity_type, COUNT(*) FROM github_activity GROUP BY activity_type")
 	if err != nil {
-		return fmt.Errorf("failed to fetch GitHub activity: %w", err)
+		return nil, err
 	}
-
-	// Insert new activity data, ignoring duplicates based on unique constraint
-	for _, activity := range activities {
-		_, err := app.DB.Exec(`
-			INSERT OR IGNORE INTO github_activity (date, repository, activity_type, count, url, github_id)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, activity.Date.Format("2006-01-02"), activity.Repository, activity.ActivityType, activity.Count, activity.URL, activity.GitHubID)
-		if err != nil {
-			return fmt.Errorf("failed to insert activity: %w", err)
+	defer rows.Close()
+	for rows.Next() {
+		var activityType string
+		var n int
+		if err := rows.Scan(&activityType, &n); err != nil {
+			return nil, err
 		}
+		counts[activityType] = n
+		counts["total"] += n
 	}
+	return counts, rows.Err()
+}
 
-	// Fetch PR comments for repositories with recent activity
-	prComments, err := app.GitHubService.FetchPRComments(username)
-	if err != nil {
-		// Log error but don't fail the whole refresh
-		fmt.Printf("Warning: Failed to fetch PR comments: %v\n", err)
-	} else {
-		// Clear old PR comments
-		_, err = app.DB.Exec("DELETE FROM pr_comments WHERE created_at < date('now', '-180 days')")
-		if err != nil {
-			fmt.Printf("Warning: Failed to clear old PR comments: %v\n", err)
-		}
+func (app *App) statusHandler(w http.ResponseWriter, r *http.Request) {
+	githubToken := app.GitHubService.Token
 
-		// Insert new PR comments
-		for _, comment := range prComments {
-			_, err := app.DB.Exec(`
-				INSERT OR REPLACE INTO pr_comments 
-				(repository, pr_number, pr_title, author, body, created_at, pr_url, comment_url)
-				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-			`, comment.Repository, comment.PRNumber, comment.PRTitle, comment.Author,
-				comment.Body, comment.CreatedAt.Format(time.RFC3339), comment.PRURL, comment.CommentURL)
-			if err != nil {
-				fmt.Printf("Warning: Failed to insert PR comment: %v\n", err)
-			}
-		}
+	// github_username is null when GITHUB_USERNAME isn't set, which only
+	// sample mode allows
+	var githubUsername *string
+	if app.Config.Username != "" {
+		githubUsername = &app.Config.Username
 	}
 
-	return nil
-}
-
-func (app *App) statusHandler(w http.ResponseWriter, r *http.Request) {
-	githubToken := os.Getenv("GITHUB_TOKEN")
-	githubUsername := os.Getenv("GITHUB_USERNAME")
-	if githubUsername == "" {
-		githubUsername = "kristofer"
+	// last_refresh_at is null until the first successful refresh
+	var lastRefresh *string
+	if value, ok, err := app.getMetadata("last_refresh_at"); err != nil {
+		slog.WarnContext(r.Context(), "failed to read last refresh time", "error", err)
+	} else if ok {
+		lastRefresh = &value
 	}
 
 	status := map[string]interface{}{
@@ -407,15 +1592,43 @@ func (app *App) statusHandler(w http.ResponseWriter, r *http.Request) {
 		"github_username":         githubUsername,
 		"database_connected":      app.DB != nil,
 		"sample_mode":             githubToken == "",
+		"last_refresh_at":         lastRefresh,
+	}
+	if githubToken == "" {
+		status["sample_owner"] = sampleUsername
+	} else if rateLimit, err := app.GitHubService.RateLimitStatus(r.Context()); err != nil {
+		slog.WarnContext(r.Context(), "failed to fetch GitHub rate limit", "error", err)
+	} else {
+		status["rate_limit"] = rateLimit
+	}
+	if counts, err := app.activityCounts(); err != nil {
+		slog.WarnContext(r.Context(), "failed to count activity rows", "error", err)
+	} else {
+		status["counts"] = counts
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
 
+// Handler for /healthz: pings the database and reports 503 if it is unreachable
+func (app *App) healthHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := app.DB.PingContext(ctx); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 // Handler for /api/projects: returns blog-style listing of projects with recent PR comments
 func (app *App) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	sixMonthsAgo := time.Now().AddDate(0, -6, 0).Format("2006-01-02")
+	since := app.GitHubService.HistoryStart().Format("2006-01-02")
 
 	// Get all repositories with activity
 	rows, err := app.DB.Query(`
@@ -427,9 +1640,9 @@ func (app *App) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
 		WHERE date >= ?
 		GROUP BY repository
 		ORDER BY latest_date DESC
-	`, sixMonthsAgo)
+	`, since)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	defer rows.Close()
@@ -440,11 +1653,11 @@ func (app *App) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
 		var totalCommits int
 		err := rows.Scan(&repo, &latestDateStr, &totalCommits, &activityTypesStr, &url)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		latestDate, _ := time.Parse("2006-01-02", latestDateStr)
+		latestDate := app.parseActivityDate(latestDateStr)
 
 		// Split activity types
 		var activityTypes []string
@@ -456,7 +1669,7 @@ func (app *App) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
 		comments, err := app.getPRCommentsForRepo(repo, 5)
 		if err != nil {
 			// Log error but continue
-			fmt.Printf("Warning: Failed to fetch PR comments for %s: %v\n", repo, err)
+			slog.WarnContext(r.Context(), "failed to load PR comments", "repo", repo, "error", err)
 			comments = []PRComment{}
 		}
 
@@ -474,19 +1687,64 @@ func (app *App) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(projects)
 }
 
+// Handler for /api/repos: returns per-repository activity totals, ordered by most recent activity
+func (app *App) getReposHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := app.DB.Query(`
+		SELECT a.repository,
+		       COALESCE(r.description, '') as description,
+		       COALESCE(r.topics, '[]') as topics,
+		       SUM(CASE WHEN a.activity_type = 'commit' THEN a.count ELSE 0 END) as total_commits,
+		       SUM(CASE WHEN a.activity_type = 'pull_request' THEN a.count ELSE 0 END) as total_pull_requests,
+		       SUM(CASE WHEN a.activity_type = 'issue' THEN a.count ELSE 0 END) as total_issues,
+		       MAX(a.date) as latest_date
+		FROM github_activity a
+		LEFT JOIN repositories r ON r.repository = a.repository
+		GROUP BY a.repository
+		ORDER BY latest_date DESC, a.repository
+	`)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	var summaries []RepoSummary
+	for rows.Next() {
+		var summary RepoSummary
+		var topics, latestDateStr string
+		err := rows.Scan(&summary.Repository, &summary.Description, &topics, &summary.TotalCommits,
+			&summary.TotalPullRequests, &summary.TotalIssues, &latestDateStr)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := json.Unmarshal([]byte(topics), &summary.Topics); err != nil || summary.Topics == nil {
+			summary.Topics = []string{}
+		}
+
+		summary.LatestDate = app.parseActivityDate(latestDateStr)
+		summary.URL = app.GitHubService.WebBase + "/" + summary.Repository
+		summaries = append(summaries, summary)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
 // Handler for /api/blog: returns blog-style listing grouped by repository with all activity types
 func (app *App) getBlogHandler(w http.ResponseWriter, r *http.Request) {
-	sixMonthsAgo := time.Now().AddDate(0, -6, 0).Format("2006-01-02")
+	since := app.GitHubService.HistoryStart().Format("2006-01-02")
 
 	// Get all activities from the database
 	rows, err := app.DB.Query(`
-		SELECT repository, date, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id
+		SELECT repository, date, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, state
 		FROM github_activity
 		WHERE date >= ?
 		ORDER BY date DESC
-	`, sixMonthsAgo)
+	`, since)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	defer rows.Close()
@@ -497,19 +1755,20 @@ func (app *App) getBlogHandler(w http.ResponseWriter, r *http.Request) {
 		url          string
 		pullRequests []GitHubActivity
 		issues       []GitHubActivity
+		reviews      []GitHubActivity
 		commits      []GitHubActivity
 	})
 
 	for rows.Next() {
-		var repo, dateStr, activityType, url, githubID string
-		var count int
-		err := rows.Scan(&repo, &dateStr, &activityType, &count, &url, &githubID)
+		var repo, dateStr, activityType, url, githubID, title, state string
+		var count, number int
+		err := rows.Scan(&repo, &dateStr, &activityType, &count, &url, &githubID, &number, &title, &state)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		date, _ := time.Parse("2006-01-02", dateStr)
+		date := app.parseActivityDate(dateStr)
 		activity := GitHubActivity{
 			Date:         date,
 			Repository:   repo,
@@ -517,6 +1776,9 @@ func (app *App) getBlogHandler(w http.ResponseWriter, r *http.Request) {
 			Count:        count,
 			URL:          url,
 			GitHubID:     githubID,
+			Number:       number,
+			Title:        title,
+			State:        state,
 		}
 
 		repoData := repoActivities[repo]
@@ -533,12 +1795,14 @@ func (app *App) getBlogHandler(w http.ResponseWriter, r *http.Request) {
 			repoData.pullRequests = append(repoData.pullRequests, activity)
 		case "issue":
 			repoData.issues = append(repoData.issues, activity)
-		case "commit", "review", "repository", "fork", "star", "activity":
+		case "review":
+			repoData.reviews = append(repoData.reviews, activity)
+		case "commit", "repository", "fork", "star", "activity":
 			// All commit-like activities go into commits section
 			repoData.commits = append(repoData.commits, activity)
 		default:
 			// Log unknown activity types for debugging
-			fmt.Printf("Unknown activity type '%s' found for repo %s, adding to commits\n", activityType, repo)
+			slog.DebugContext(r.Context(), "unknown activity type, adding to commits", "activity_type", activityType, "repo", repo)
 			repoData.commits = append(repoData.commits, activity)
 		}
 
@@ -554,6 +1818,7 @@ func (app *App) getBlogHandler(w http.ResponseWriter, r *http.Request) {
 			URL:          data.url,
 			PullRequests: data.pullRequests,
 			Issues:       data.issues,
+			Reviews:      data.reviews,
 			Commits:      data.commits,
 		})
 	}
@@ -592,7 +1857,7 @@ func (app *App) getPRCommentsForRepo(repo string, limit int) ([]PRComment, error
 		parsedTime, err := time.Parse(time.RFC3339, createdAtStr)
 		if err != nil {
 			// Log error and use current time as fallback
-			fmt.Printf("Warning: Failed to parse comment timestamp %s: %v\n", createdAtStr, err)
+			slog.Warn("failed to parse comment timestamp", "created_at", createdAtStr, "error", err)
 			comment.CreatedAt = time.Now()
 		} else {
 			comment.CreatedAt = parsedTime
@@ -603,39 +1868,221 @@ func (app *App) getPRCommentsForRepo(repo string, limit int) ([]PRComment, error
 	return comments, nil
 }
 
+// checkGitHubToken logs at startup whether the app runs on sample data or, when
+// a token is configured, whether GitHub accepts it, so a bad token shows up
+// before the first refresh rather than as a 401 midway through it. Failures are
+// only logged; the app keeps running either way.
+func (app *App) checkGitHubToken() {
+	if app.GitHubService.Token == "" {
+		slog.Info("no GITHUB_TOKEN set, serving sample data instead of GitHub activity")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	login, remaining, err := app.GitHubService.ValidateToken(ctx)
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized:
+		slog.Error("GITHUB_TOKEN was rejected by GitHub, it is invalid or expired; refreshes will fail until it is replaced")
+	case err != nil:
+		slog.Warn("could not validate GITHUB_TOKEN", "error", err)
+	default:
+		slog.Info("GITHUB_TOKEN is valid", "login", login, "rate_limit_remaining", remaining)
+	}
+}
+
+// refreshLoop refreshes every interval until ctx is cancelled, which also
+// aborts a refresh in progress. A run that finds a manual refresh underway is
+// skipped until the next tick.
+func (app *App) refreshLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		result, err := app.fetchGitHubActivity(ctx, RefreshOptions{})
+		switch {
+		case errors.Is(err, errRefreshInProgress):
+			slog.Info("skipped scheduled refresh, another refresh is in progress")
+		case err != nil && ctx.Err() != nil:
+			slog.Info("scheduled refresh cancelled by shutdown")
+		case err != nil:
+			slog.Error("scheduled refresh failed", "duration", time.Since(start), "error", err)
+		default:
+			slog.Info("scheduled refresh finished", "duration", time.Since(start), "fetched", result.Fetched, "inserted", result.Inserted)
+		}
+	}
+}
+
+// refreshOnce runs a single refresh for cron-style use and returns the
+// process exit code
+func (app *App) refreshOnce() int {
+	defer app.DB.Close()
+
+	result, err := app.fetchGitHubActivity(context.Background(), RefreshOptions{})
+	if err != nil {
+		slog.Error("refresh failed", "error", err)
+		return 1
+	}
+
+	fmt.Printf("Refreshed GitHub activity: %d fetched, %d new rows inserted\n", result.Fetched, result.Inserted)
+	return 0
+}
+
+// seedOnce stores the sample dataset through the regular refresh path, so the
+// handlers query real rows during frontend development, and returns the process
+// exit code. The GitHub token is ignored, so nothing is fetched from GitHub.
+func (app *App) seedOnce() int {
+	defer app.DB.Close()
+
+	app.GitHubService.Token = ""
+	result, err := app.fetchGitHubActivity(context.Background(), RefreshOptions{})
+	if err != nil {
+		slog.Error("seed failed", "error", err)
+		return 1
+	}
+
+	fmt.Printf("Seeded sample activity: %d items, %d new rows inserted\n", result.Fetched, result.Inserted)
+	return 0
+}
+
 func main() {
+	refreshOnly := flag.Bool("refresh", false, "fetch GitHub activity once and exit without starting the server")
+	seed := flag.Bool("seed", false, "store the sample dataset (or SAMPLE_DATA_PATH) in the database and exit")
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+	setupLogging(cfg.LogLevel)
+
 	app := &App{
-		GitHubService: NewGitHubService(),
+		Config:        cfg,
+		GitHubService: NewGitHubService(cfg),
+		APIToken:      cfg.APIToken,
+		ProtectReads:  cfg.ProtectReads,
 	}
 
 	// Initialize database
 	if err := app.initDB(); err != nil {
-		fmt.Println("Failed to initialize database:", err)
-		return
+		slog.Error("failed to initialize database", "error", err)
+		os.Exit(1)
 	}
 	defer app.DB.Close()
+	app.GitHubService.Cache = &dbResponseCache{db: app.DB}
+
+	if *seed {
+		os.Exit(app.seedOnce())
+	}
+	app.checkGitHubToken()
+	if *refreshOnly {
+		os.Exit(app.refreshOnce())
+	}
+
+	app.Metrics = newMetrics(app.DB)
+	app.GitHubService.ObserveRequest = app.Metrics.observeGitHubRequest
 
 	// Set up routes
 	r := http.NewServeMux()
 	r.HandleFunc("/", app.indexHandler)
-	r.HandleFunc("/api/activity", app.getActivityHandler)
-	r.HandleFunc("/api/commits", app.getCommitsHandler)
-	r.HandleFunc("/api/projects", app.getProjectsHandler)
-	r.HandleFunc("/api/blog", app.getBlogHandler)
-	r.HandleFunc("/api/refresh", app.refreshActivityHandler)
-	r.HandleFunc("/api/status", app.statusHandler)
+	r.HandleFunc("/api/activity", app.readAccess(app.getActivityHandler))
+	r.HandleFunc("/api/activity/", app.readAccess(app.getActivityItemHandler))
+	r.HandleFunc("/api/since", app.readAccess(app.getSinceHandler))
+	r.HandleFunc("/api/commits", app.readAccess(app.getCommitsHandler))
+	r.HandleFunc("/api/commits/", app.readAccess(app.getCommitHandler))
+	r.HandleFunc("/api/issues", app.readAccess(app.getIssuesHandler))
+	r.HandleFunc("/api/projects", app.readAccess(app.getProjectsHandler))
+	r.HandleFunc("/api/repositories", app.readAccess(app.getRepositoriesHandler))
+	r.HandleFunc("/api/repos", app.readAccess(app.getReposHandler))
+	r.HandleFunc("/api/repos/", app.readAccess(app.repoHandler))
+	r.HandleFunc("/api/summary", app.readAccess(app.getSummaryHandler))
+	r.HandleFunc("/api/calendar", app.readAccess(app.getCalendarHandler))
+	r.HandleFunc("/api/contributions", app.readAccess(app.getContributionsHandler))
+	r.HandleFunc("/api/languages", app.readAccess(app.getLanguagesHandler))
+	r.HandleFunc("/api/timeline", app.readAccess(app.getTimelineHandler))
+	r.HandleFunc("/api/stats/languages-over-time", app.readAccess(app.getLanguagesOverTimeHandler))
+	r.HandleFunc("/api/blog", app.readAccess(app.getBlogHandler))
+	r.HandleFunc("/api/search", app.readAccess(app.getSearchHandler))
+	r.HandleFunc("/api/feed", app.readAccess(app.getFeedHandler))
+	r.HandleFunc("/api/export.csv", app.readAccess(app.exportCSVHandler))
+	r.HandleFunc("/api/export.ndjson", app.readAccess(app.exportNDJSONHandler))
+	r.HandleFunc("/api/refresh", app.requireToken(app.refreshActivityHandler))
+	r.HandleFunc("/api/refresh/stream", app.requireToken(app.refreshStreamHandler))
+	r.HandleFunc("/api/refresh/repo", app.requireToken(app.refreshRepoHandler))
+	r.HandleFunc("/api/archive", app.requireToken(app.archiveHandler))
+	r.HandleFunc("/api/status", app.readAccess(app.statusHandler))
+	r.HandleFunc("/healthz", app.healthHandler)
+	r.Handle("/metrics", app.Metrics.Handler())
+	r.HandleFunc("/feed.atom", app.readAccess(app.atomFeedHandler))
+	r.HandleFunc("/feed.json", app.readAccess(app.jsonFeedHandler))
 
 	// Serve static files
 	fs := http.FileServer(http.Dir("./static"))
 	r.Handle("/static/", http.StripPrefix("/static/", fs))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Handlers inherit this context, so requests still running when the
+	// shutdown timeout expires are cancelled rather than abandoned
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	// BIND_ADDR restricts the interface to listen on, such as 127.0.0.1;
+	// empty means all interfaces
+	addr := net.JoinHostPort(cfg.BindAddr, cfg.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Error("failed to listen", "addr", addr, "error", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:        addr,
+		Handler:     logRequests(recoverPanics(app.basicAuth(r))),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	// Stop accepting connections on SIGINT/SIGTERM and let in-flight requests
+	// (such as a refresh writing to SQLite) finish before the database closes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		slog.Info("server starting", "addr", listener.Addr().String())
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("server error", "error", err)
+			stop()
+		}
+	}()
+
+	// Scheduled refreshes stop with the server; wait for a running one to
+	// wind down before the database is closed
+	refreshLoopDone := make(chan struct{})
+	if cfg.RefreshInterval > 0 {
+		slog.Info("scheduled refreshes enabled", "interval", cfg.RefreshInterval)
+		go func() {
+			defer close(refreshLoopDone)
+			app.refreshLoop(ctx, cfg.RefreshInterval)
+		}()
+	} else {
+		close(refreshLoopDone)
 	}
 
-	fmt.Printf("Server starting on port %s\n", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		fmt.Println("Server error:", err)
+	<-ctx.Done()
+	slog.Info("shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown error", "error", err)
+		cancelRequests()
 	}
+	<-refreshLoopDone
 }
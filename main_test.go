@@ -0,0 +1,299 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMain(m *testing.M) {
+	// Keep migration and refresh logging out of the test output
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newTestApp returns an App backed by a migrated database in a temporary
+// directory and a GitHubService without a token
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "activity.db")+"?_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &Config{Username: "octo", HistoryMonths: 12}
+	app := &App{Config: cfg, DB: db, GitHubService: &GitHubService{HistoryMonths: cfg.HistoryMonths}}
+	if err := app.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.setupSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+// store upserts fetched activity as a refresh would
+func (app *App) store(t *testing.T, fetched UserActivity) {
+	t.Helper()
+	tx, err := app.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := storeUserActivity(tx, fetched); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitIDs returns the github_id of every stored commit, oldest first
+func (app *App) commitIDs(t *testing.T) []string {
+	t.Helper()
+	rows, err := app.DB.Query("SELECT github_id FROM github_activity WHERE activity_type = 'commit' ORDER BY date, id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestSwitchingCommitModesReplacesCommitRows(t *testing.T) {
+	app := newTestApp(t)
+	since := time.Now().AddDate(0, -1, 0)
+	day := time.Now().AddDate(0, 0, -3).UTC().Truncate(24 * time.Hour)
+
+	// A REST refresh stores commits by SHA and advances the watermark
+	app.store(t, UserActivity{
+		Since: since,
+		Activities: []GitHubActivity{
+			{Date: day.Add(time.Hour), Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "aaa"},
+			{Date: day.Add(2 * time.Hour), Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "bbb"},
+		},
+		CommitWatermarks: map[string]time.Time{"octo/app": day.Add(2 * time.Hour)},
+	})
+
+	// A GraphQL refresh counts the same commits per day
+	app.store(t, UserActivity{
+		Since:        since,
+		DailyCommits: true,
+		Activities: []GitHubActivity{
+			{Date: day, Repository: "octo/app", ActivityType: "commit", Count: 2, GitHubID: dailyCommitPrefix + day.Format("2006-01-02")},
+		},
+	})
+	if got := app.commitIDs(t); len(got) != 1 || got[0] != dailyCommitPrefix+day.Format("2006-01-02") {
+		t.Fatalf("after GraphQL refresh got commits %v, want only the daily count", got)
+	}
+	watermarks, err := app.commitWatermarks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watermarks) != 0 {
+		t.Errorf("got watermarks %v after GraphQL refresh, want them cleared", watermarks)
+	}
+
+	// Falling back to REST replaces the daily count with the SHAs again
+	app.store(t, UserActivity{
+		Since: since,
+		Activities: []GitHubActivity{
+			{Date: day.Add(time.Hour), Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "aaa"},
+			{Date: day.Add(2 * time.Hour), Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "bbb"},
+		},
+	})
+	if got := app.commitIDs(t); len(got) != 2 || got[0] != "aaa" || got[1] != "bbb" {
+		t.Errorf("after REST refresh got commits %v, want [aaa bbb]", got)
+	}
+}
+
+func TestConcurrentRefreshesFetchOnce(t *testing.T) {
+	app := newTestApp(t)
+	m := newMockGitHub(t)
+	app.GitHubService = m.service()
+
+	// The first refresh blocks while listing repositories until released
+	listing := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	m.handle("/users/octo/repos", func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(listing) })
+		<-release
+		w.Write([]byte("[]"))
+	})
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.refreshActivityHandler(first, httptest.NewRequest(http.MethodPost, "/api/refresh", nil))
+	}()
+	<-listing
+
+	second := httptest.NewRecorder()
+	app.refreshActivityHandler(second, httptest.NewRequest(http.MethodPost, "/api/refresh", nil))
+	listings := m.requests("/users/octo/repos")
+	close(release)
+	<-done
+
+	if second.Code != http.StatusConflict {
+		t.Errorf("second refresh got status %d, want %d", second.Code, http.StatusConflict)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(second.Body).Decode(&body); err != nil || body.Error != errRefreshInProgress.Error() {
+		t.Errorf("second refresh got body %v (%v), want a JSON error %q", body, err, errRefreshInProgress)
+	}
+	if first.Code != http.StatusOK {
+		t.Errorf("first refresh got status %d, want %d: %s", first.Code, http.StatusOK, first.Body)
+	}
+	if listings != 1 {
+		t.Errorf("repositories were listed %d times while the first refresh ran, want once", listings)
+	}
+}
+
+func TestGroupsWithEqualDatesAreOrderedByRepository(t *testing.T) {
+	app := newTestApp(t)
+	date := time.Now().AddDate(0, 0, -1).UTC().Truncate(time.Hour)
+	var activities []GitHubActivity
+	for i, repo := range []string{"octo/delta", "octo/alpha", "octo/charlie", "octo/bravo"} {
+		activities = append(activities,
+			GitHubActivity{Date: date, Repository: repo, ActivityType: "commit", Count: 1, GitHubID: "sha" + repo},
+			GitHubActivity{Date: date, Repository: repo, ActivityType: "issue", Count: 1, GitHubID: "issue-" + strconv.Itoa(i+1), Number: i + 1},
+		)
+	}
+	app.store(t, UserActivity{Activities: activities})
+
+	tests := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/api/commits", app.getCommitsHandler},
+		{"/api/issues", app.getIssuesHandler},
+	}
+	for _, tt := range tests {
+		var got []string
+		for page := 1; page <= 2; page++ {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, tt.path+"?limit=2&page="+strconv.Itoa(page), nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s page %d: status %d: %s", tt.path, page, rec.Code, rec.Body)
+			}
+			var body struct {
+				Data []struct {
+					Repository string `json:"repository"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			for _, group := range body.Data {
+				got = append(got, group.Repository)
+			}
+		}
+
+		want := []string{"octo/alpha", "octo/bravo", "octo/charlie", "octo/delta"}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: got repositories %v across both pages, want %v", tt.path, got, want)
+		}
+	}
+}
+
+func TestSinceIsLimitedAndChainedByCursor(t *testing.T) {
+	app := newTestApp(t)
+	date := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	// Two rows share a date so the cursor has to break the tie by id
+	app.store(t, UserActivity{Activities: []GitHubActivity{
+		{Date: date, Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "aaa"},
+		{Date: date, Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "bbb"},
+		{Date: date.Add(time.Minute), Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "ccc"},
+	}})
+
+	var got []string
+	path := "/api/since?limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("cursor did not reach the end")
+		}
+		rec := httptest.NewRecorder()
+		app.getSinceHandler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, rec.Code, rec.Body)
+		}
+		var body struct {
+			Data []struct {
+				GitHubID string `json:"github_id"`
+			} `json:"data"`
+			NextCursor *string `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data) > 2 {
+			t.Fatalf("%s: got %d activities, want at most 2", path, len(body.Data))
+		}
+		for _, activity := range body.Data {
+			got = append(got, activity.GitHubID)
+		}
+		path = ""
+		if body.NextCursor != nil {
+			path = "/api/since?limit=2&cursor=" + *body.NextCursor
+		}
+	}
+
+	if want := []string{"aaa", "bbb", "ccc"}; !slices.Equal(got, want) {
+		t.Errorf("got activities %v across pages, want %v", got, want)
+	}
+}
+
+func TestActivityRejectsPage(t *testing.T) {
+	app := newTestApp(t)
+	rec := httptest.NewRecorder()
+	app.getActivityHandler(rec, httptest.NewRequest(http.MethodGet, "/api/activity?page=2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error == "" {
+		t.Errorf("got body %+v (%v), want a JSON error", body, err)
+	}
+}
+
+func TestSingleRepoRefreshIsCountedInMetrics(t *testing.T) {
+	app := newTestApp(t)
+	app.Metrics = newMetrics(app.DB)
+
+	for _, name := range []string{"kristofer/RecentRepos", "octo/unknown"} {
+		rec := httptest.NewRecorder()
+		app.refreshRepoHandler(rec, httptest.NewRequest(http.MethodPost, "/api/refresh/repo?name="+name, nil))
+	}
+
+	if got := testutil.ToFloat64(app.Metrics.refreshes); got != 2 {
+		t.Errorf("got %v refreshes, want 2", got)
+	}
+	if got := testutil.ToFloat64(app.Metrics.refreshFailures); got != 1 {
+		t.Errorf("got %v refresh failures, want 1 for the unknown repository", got)
+	}
+	if got := testutil.ToFloat64(app.Metrics.rowsInserted); got == 0 {
+		t.Error("got no inserted rows, want the sample repository's activity")
+	}
+}
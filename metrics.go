@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors exposed on /metrics
+type Metrics struct {
+	registry        *prometheus.Registry
+	refreshes       prometheus.Counter
+	refreshFailures prometheus.Counter
+	rowsInserted    prometheus.Counter
+	githubRequests  *prometheus.CounterVec
+}
+
+// newMetrics registers the application's collectors, along with the standard
+// Go runtime and process collectors, on a dedicated registry
+func newMetrics(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		refreshes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "recentrepos_refreshes_total",
+			Help: "Number of refreshes run.",
+		}),
+		refreshFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "recentrepos_refresh_failures_total",
+			Help: "Number of refreshes that failed.",
+		}),
+		rowsInserted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "recentrepos_rows_inserted_total",
+			Help: "Number of new activity rows written by refreshes.",
+		}),
+		githubRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "recentrepos_github_requests_total",
+			Help: "Number of GitHub API requests by response status code.",
+		}, []string{"code"}),
+	}
+
+	activityRows := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "recentrepos_activity_rows",
+		Help: "Number of rows in the github_activity table.",
+	}, func() float64 {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM github_activity").Scan(&count); err != nil {
+			slog.Warn("failed to count activity rows for metrics", "error", err)
+			return 0
+		}
+		return float64(count)
+	})
+
+	m.registry.MustRegister(
+		m.refreshes,
+		m.refreshFailures,
+		m.rowsInserted,
+		m.githubRequests,
+		activityRows,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observeRefresh records the outcome of a refresh. It is safe to call on a nil
+// Metrics, as in the one-shot -refresh mode.
+func (m *Metrics) observeRefresh(result RefreshResult, err error) {
+	if m == nil {
+		return
+	}
+	m.refreshes.Inc()
+	if err != nil {
+		m.refreshFailures.Inc()
+		return
+	}
+	m.rowsInserted.Add(float64(result.Inserted))
+}
+
+// observeGitHubRequest counts a GitHub API response by status code, or "error"
+// when the request failed before a response arrived
+func (m *Metrics) observeGitHubRequest(code string) {
+	if m == nil {
+		return
+	}
+	m.githubRequests.WithLabelValues(code).Inc()
+}
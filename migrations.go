@@ -0,0 +1,322 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// migration is one step of the database schema. Steps are numbered by their
+// position in migrations, and each runs once, in its own transaction, when the
+// schema_version recorded in the database is below its number. New schema
+// changes are appended; existing steps must never be edited or reordered.
+type migration struct {
+	description string
+	apply       func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in order. The early steps predate
+// schema_version and are written to be idempotent, since databases created by
+// older versions already have some of them applied.
+var migrations = []migration{
+	{"create tables", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS github_activity (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			repository TEXT NOT NULL,
+			activity_type TEXT NOT NULL,
+			count INTEGER DEFAULT 1,
+			url TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_date ON github_activity(date);
+		CREATE INDEX IF NOT EXISTS idx_repo ON github_activity(repository);
+
+		CREATE TABLE IF NOT EXISTS pr_comments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			repository TEXT NOT NULL,
+			pr_number INTEGER NOT NULL,
+			pr_title TEXT NOT NULL,
+			author TEXT NOT NULL,
+			body TEXT,
+			created_at TEXT NOT NULL,
+			pr_url TEXT,
+			comment_url TEXT,
+			fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_pr_comments_repo ON pr_comments(repository);
+		CREATE INDEX IF NOT EXISTS idx_pr_comments_created ON pr_comments(created_at);
+
+		CREATE TABLE IF NOT EXISTS github_cache (
+			key TEXT PRIMARY KEY,
+			etag TEXT NOT NULL,
+			body BLOB NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS repo_snapshots (
+			repository TEXT NOT NULL,
+			date TEXT NOT NULL,
+			stars INTEGER NOT NULL,
+			forks INTEGER NOT NULL,
+			PRIMARY KEY (repository, date)
+		);
+
+		CREATE TABLE IF NOT EXISTS repositories (
+			repository TEXT PRIMARY KEY,
+			language TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		`)
+		return err
+	}},
+
+	{"add github_id to activity", func(tx *sql.Tx) error {
+		exists, err := columnExists(tx, "github_activity", "github_id")
+		if err != nil || exists {
+			return err
+		}
+		if _, err := tx.Exec(`ALTER TABLE github_activity ADD COLUMN github_id TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to add github_id column: %w", err)
+		}
+
+		// Rows stored before github_id existed may be duplicated; keep the
+		// oldest of each
+		_, err = tx.Exec(`
+			DELETE FROM github_activity
+			WHERE id NOT IN (
+				SELECT MIN(id)
+				FROM github_activity
+				GROUP BY date, repository, activity_type, github_id
+			)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to clean up duplicate entries: %w", err)
+		}
+		return nil
+	}},
+
+	{"add commit, pull request and issue details to activity", func(tx *sql.Tx) error {
+		return addColumnsIfMissing(tx, "github_activity", []columnDef{
+			{"number", "INTEGER NOT NULL DEFAULT 0"},
+			{"title", "TEXT NOT NULL DEFAULT ''"},
+			{"state", "TEXT NOT NULL DEFAULT ''"},
+			{"message", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+
+	{"add description and topics to repositories", func(tx *sql.Tx) error {
+		return addColumnsIfMissing(tx, "repositories", []columnDef{
+			{"description", "TEXT NOT NULL DEFAULT ''"},
+			// A JSON array
+			{"topics", "TEXT NOT NULL DEFAULT '[]'"},
+		})
+	}},
+
+	{"store full RFC3339 activity timestamps", func(tx *sql.Tx) error {
+		// Rows written before this kept only the day, so they become midnight
+		// UTC until a refresh corrects them
+		_, err := tx.Exec(`UPDATE github_activity SET date = date || 'T00:00:00Z' WHERE length(date) = 10`)
+		return err
+	}},
+
+	{"identify activity items by GitHub id", func(tx *sql.Tx) error {
+		// Items are identified by their GitHub id rather than their date, so a
+		// refresh can correct a timestamp
+		var exists bool
+		err := tx.QueryRow(`
+			SELECT COUNT(*) > 0
+			FROM sqlite_master
+			WHERE type = 'index' AND name = 'idx_unique_activity_item'
+		`).Scan(&exists)
+		if err != nil || exists {
+			return err
+		}
+
+		if _, err := tx.Exec(`DROP INDEX IF EXISTS idx_unique_activity`); err != nil {
+			return fmt.Errorf("failed to drop old unique index: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			DELETE FROM github_activity
+			WHERE github_id != '' AND id NOT IN (
+				SELECT MIN(id)
+				FROM github_activity
+				WHERE github_id != ''
+				GROUP BY repository, activity_type, github_id
+			)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to clean up duplicate entries: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			CREATE UNIQUE INDEX idx_unique_activity_item
+			ON github_activity(repository, activity_type, github_id)
+			WHERE github_id != ''
+		`)
+		return err
+	}},
+
+	{"create activity archive", func(tx *sql.Tx) error {
+		// Old activity moved out by /api/archive, mirroring github_activity
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS activity_archive (
+				id INTEGER PRIMARY KEY,
+				date TEXT NOT NULL,
+				repository TEXT NOT NULL,
+				activity_type TEXT NOT NULL,
+				count INTEGER DEFAULT 1,
+				url TEXT,
+				created_at DATETIME,
+				github_id TEXT NOT NULL DEFAULT '',
+				number INTEGER NOT NULL DEFAULT 0,
+				title TEXT NOT NULL DEFAULT '',
+				state TEXT NOT NULL DEFAULT '',
+				message TEXT NOT NULL DEFAULT '',
+				archived_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_archive_date ON activity_archive(date);
+		`)
+		return err
+	}},
+
+	{"index activity by GitHub id", func(tx *sql.Tx) error {
+		// Look up single items, such as a commit by SHA, without the repository
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_github_id ON github_activity(github_id)`)
+		return err
+	}},
+
+	{"add commit watermark to repositories", func(tx *sql.Tx) error {
+		return addColumnsIfMissing(tx, "repositories", []columnDef{
+			{"last_commit_at", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+
+	{"flag private repository activity", func(tx *sql.Tx) error {
+		for _, table := range []string{"github_activity", "activity_archive"} {
+			err := addColumnsIfMissing(tx, table, []columnDef{{"private", "INTEGER NOT NULL DEFAULT 0"}})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+
+	{"flag activity of deleted repositories as stale", func(tx *sql.Tx) error {
+		for _, table := range []string{"github_activity", "activity_archive"} {
+			err := addColumnsIfMissing(tx, table, []columnDef{{"stale", "INTEGER NOT NULL DEFAULT 0"}})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+
+	{"store activity dates in UTC", func(tx *sql.Tx) error {
+		// Earlier builds stored commits with the TIMEZONE offset; strftime
+		// converts those timestamps back to UTC
+		for _, table := range []string{"github_activity", "activity_archive"} {
+			_, err := tx.Exec(`
+				UPDATE ` + table + `
+				SET date = strftime('%Y-%m-%dT%H:%M:%SZ', date)
+				WHERE length(date) > 10 AND substr(date, -1) != 'Z' AND strftime('%Y-%m-%dT%H:%M:%SZ', date) IS NOT NULL
+			`)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+}
+
+// migrate brings the database schema up to date, applying every migration
+// past the recorded schema_version
+func (app *App) migrate() error {
+	if _, err := app.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	var version int
+	if err := app.DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := app.applyMigration(i+1, migrations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMigration runs a migration and records its version in one transaction
+func (app *App) applyMigration(version int, m migration) error {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.apply(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", version, m.description, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_version`); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, version); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", version, err)
+	}
+
+	slog.Info("applied database migration", "version", version, "description", m.description)
+	return nil
+}
+
+// columnDef is a column name and its SQL definition
+type columnDef struct{ name, definition string }
+
+// columnExists reports whether a table has the named column
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info(?)
+		WHERE name = ?
+	`, table, column).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for %s column: %w", column, err)
+	}
+	return exists, nil
+}
+
+// addColumnsIfMissing adds columns to an existing table, skipping any that are
+// already present
+func addColumnsIfMissing(tx *sql.Tx, table string, columns []columnDef) error {
+	for _, column := range columns {
+		exists, err := columnExists(tx, table, column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column.name, column.definition)); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", column.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepoSnapshot is a repository's star and fork count on a given day
+type RepoSnapshot struct {
+	Date  string `json:"date"`
+	Stars int    `json:"stars"`
+	Forks int    `json:"forks"`
+}
+
+// Handler for /api/repos/{owner}/{repo}/...: dispatches per-repository endpoints
+func (app *App) repoHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/repos/"), "/")
+	if len(parts) == 1 && parts[0] == "top" {
+		app.getTopReposHandler(w, r)
+		return
+	}
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+	repository := parts[0] + "/" + parts[1]
+
+	if len(parts) == 2 {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		app.requireToken(func(w http.ResponseWriter, r *http.Request) {
+			app.deleteRepoHandler(w, r, repository)
+		})(w, r)
+		return
+	}
+
+	switch parts[2] {
+	case "activity":
+		app.getRepoActivityHandler(w, r, repository)
+	case "stars":
+		app.getRepoStarsHandler(w, r, repository)
+	default:
+		writeJSONError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// deleteRepoHandler purges everything stored for a repository, such as after
+// it was archived or renamed, and reports how many activity rows were removed
+func (app *App) deleteRepoHandler(w http.ResponseWriter, r *http.Request, repository string) {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM github_activity WHERE repository = ?", repository)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, table := range []string{"pr_comments", "repo_snapshots", "repositories"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE repository = ?", repository); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"repository": repository,
+		"deleted":    deleted,
+	})
+}
+
+// getRepoActivityHandler returns every stored activity for a repository, newest
+// first, or 404 if nothing has been recorded for it
+func (app *App) getRepoActivityHandler(w http.ResponseWriter, r *http.Request, repository string) {
+	rows, err := app.DB.Query(`
+		SELECT id, date, repository, activity_type, count, COALESCE(url, '') as url, COALESCE(github_id, '') as github_id,
+		       number, title, state, message, private, stale
+		FROM github_activity
+		WHERE repository = ?
+		ORDER BY date DESC, id DESC
+	`, repository)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	var activities []GitHubActivity
+	for rows.Next() {
+		var activity GitHubActivity
+		var dateStr string
+		err := rows.Scan(&activity.ID, &dateStr, &activity.Repository, &activity.ActivityType, &activity.Count,
+			&activity.URL, &activity.GitHubID, &activity.Number, &activity.Title, &activity.State, &activity.Message, &activity.Private, &activity.Stale)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		activity.Date = app.parseActivityDate(dateStr)
+		activities = append(activities, activity)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if len(activities) == 0 {
+		writeJSONError(w, http.StatusNotFound, "no activity recorded for repository "+repository)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activities)
+}
+
+// RepoCount is a repository's total of one activity type
+type RepoCount struct {
+	Repository string `json:"repository"`
+	Count      int    `json:"count"`
+	URL        string `json:"url"`
+}
+
+// topRepoMetrics are the activity types /api/repos/top can rank by
+var topRepoMetrics = []string{"commit", "pull_request", "issue", "review"}
+
+// getTopReposHandler returns the repositories with the most activity of one
+// type (metric, default commit) over the history window, busiest first. The
+// plural spellings, such as metric=commits, are accepted too.
+func (app *App) getTopReposHandler(w http.ResponseWriter, r *http.Request) {
+	metric := "commit"
+	if m := r.URL.Query().Get("metric"); m != "" {
+		metric = ""
+		for _, candidate := range topRepoMetrics {
+			if m == candidate || m == candidate+"s" {
+				metric = candidate
+			}
+		}
+		if metric == "" {
+			writeJSONError(w, http.StatusBadRequest, "invalid metric: "+m+", expected one of "+strings.Join(topRepoMetrics, ", "))
+			return
+		}
+	}
+
+	limit := 10
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit: "+limitStr+", expected a positive integer")
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	since := app.GitHubService.HistoryStart().Format("2006-01-02")
+	rows, err := app.DB.Query(`
+		SELECT repository, SUM(count) as total
+		FROM github_activity
+		WHERE activity_type = ? AND date >= ?
+		GROUP BY repository
+		ORDER BY total DESC, repository
+		LIMIT ?
+	`, metric, since, limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	repos := []RepoCount{}
+	for rows.Next() {
+		var repo RepoCount
+		if err := rows.Scan(&repo.Repository, &repo.Count); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		repo.URL = app.GitHubService.WebBase + "/" + repo.Repository
+		repos = append(repos, repo)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"metric": metric,
+		"data":   repos,
+	})
+}
+
+// getRepoStarsHandler returns the daily star and fork counts recorded for a
+// repository over the history window, oldest first
+func (app *App) getRepoStarsHandler(w http.ResponseWriter, r *http.Request, repository string) {
+	since := app.GitHubService.HistoryStart().Format("2006-01-02")
+
+	rows, err := app.DB.Query(`
+		SELECT date, stars, forks
+		FROM repo_snapshots
+		WHERE repository = ? AND date >= ?
+		ORDER BY date
+	`, repository, since)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	snapshots := []RepoSnapshot{}
+	for rows.Next() {
+		var snapshot RepoSnapshot
+		if err := rows.Scan(&snapshot.Date, &snapshot.Stars, &snapshot.Forks); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"repository": repository,
+		"snapshots":  snapshots,
+	})
+}
+
+// Handler for /api/repositories: returns the names of the repositories with
+// stored activity, alphabetically, for filter dropdowns. since (YYYY-MM-DD)
+// limits it to repositories with activity on or after that day.
+func (app *App) getRepositoriesHandler(w http.ResponseWriter, r *http.Request) {
+	query := "SELECT DISTINCT repository FROM github_activity"
+	var args []interface{}
+	if since := r.URL.Query().Get("since"); since != "" {
+		if _, err := time.Parse("2006-01-02", since); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid since date, expected YYYY-MM-DD")
+			return
+		}
+		query += " WHERE date >= ?"
+		args = append(args, since)
+	}
+	query += " ORDER BY repository"
+
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	repositories := []string{}
+	for rows.Next() {
+		var repository string
+		if err := rows.Scan(&repository); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		repositories = append(repositories, repository)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(repositories)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// searchIndexTriggers keep the activity_fts index in step with github_activity
+var searchIndexTriggers = map[string]string{
+	"activity_fts_insert": `
+		CREATE TRIGGER IF NOT EXISTS activity_fts_insert AFTER INSERT ON github_activity BEGIN
+			INSERT INTO activity_fts (rowid, message, title) VALUES (new.id, new.message, new.title);
+		END`,
+	"activity_fts_delete": `
+		CREATE TRIGGER IF NOT EXISTS activity_fts_delete AFTER DELETE ON github_activity BEGIN
+			INSERT INTO activity_fts (activity_fts, rowid, message, title) VALUES ('delete', old.id, old.message, old.title);
+		END`,
+	"activity_fts_update": `
+		CREATE TRIGGER IF NOT EXISTS activity_fts_update AFTER UPDATE ON github_activity BEGIN
+			INSERT INTO activity_fts (activity_fts, rowid, message, title) VALUES ('delete', old.id, old.message, old.title);
+			INSERT INTO activity_fts (rowid, message, title) VALUES (new.id, new.message, new.title);
+		END`,
+}
+
+// setupSearchIndex creates the FTS5 index over commit messages and titles that
+// backs /api/search, and sets SearchFTS. It lives outside the versioned
+// migrations because FTS5 depends on how the binary was built: the SQLite
+// driver only includes it with -tags sqlite_fts5. Without it, search falls
+// back to LIKE matching and any triggers left by an FTS5 build are dropped, as
+// they would fail every write; the index is rebuilt once they are back.
+func (app *App) setupSearchIndex() error {
+	var fts5 bool
+	if err := app.DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		return fmt.Errorf("failed to check for FTS5 support: %w", err)
+	}
+	if !fts5 {
+		slog.Info("SQLite driver lacks FTS5, search uses LIKE matching; build with -tags sqlite_fts5 for ranked full-text search")
+		for name := range searchIndexTriggers {
+			if _, err := app.DB.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+				return fmt.Errorf("failed to drop search index trigger: %w", err)
+			}
+		}
+		app.SearchFTS = false
+		return nil
+	}
+
+	_, err := app.DB.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS activity_fts
+		USING fts5(message, title, content='github_activity', content_rowid='id')
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	var existing int
+	err = app.DB.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'github_activity' AND name LIKE 'activity_fts_%'
+	`).Scan(&existing)
+	if err != nil {
+		return fmt.Errorf("failed to check search index triggers: %w", err)
+	}
+	if existing < len(searchIndexTriggers) {
+		tx, err := app.DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin search index setup: %w", err)
+		}
+		defer tx.Rollback()
+		for _, trigger := range searchIndexTriggers {
+			if _, err := tx.Exec(trigger); err != nil {
+				return fmt.Errorf("failed to create search index trigger: %w", err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO activity_fts (activity_fts) VALUES ('rebuild')`); err != nil {
+			return fmt.Errorf("failed to build search index: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit search index setup: %w", err)
+		}
+		slog.Info("built full-text search index")
+	}
+
+	app.SearchFTS = true
+	return nil
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term so it matches
+// literally; queries using it must declare ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ftsQuery turns free text into an FTS5 query matching activity that contains
+// every word, as a prefix, so q=refactor finds "refactoring" too. Words are
+// quoted so FTS5 operators and punctuation in them are taken literally.
+func ftsQuery(q string) string {
+	var terms []string
+	for _, word := range strings.Fields(q) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Handler for /api/search: returns the activity whose commit message or pull
+// request/issue title matches q, paginated like /api/feed. With the FTS5 index
+// every word of q must appear (as a word prefix) and the best matches come
+// first; otherwise q is matched as a case-insensitive substring, newest first.
+func (app *App) getSearchHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing search query q")
+		return
+	}
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from := "github_activity a"
+	where := `(a.message LIKE ? ESCAPE '\' OR a.title LIKE ? ESCAPE '\')`
+	order := "a.date DESC, a.id DESC"
+	pattern := "%" + likeEscaper.Replace(q) + "%"
+	args := []interface{}{pattern, pattern}
+	if app.SearchFTS {
+		from = "activity_fts JOIN github_activity a ON a.id = activity_fts.rowid"
+		where = "activity_fts MATCH ?"
+		order = "activity_fts.rank, " + order
+		args = []interface{}{ftsQuery(q)}
+	}
+
+	var total int
+	err = app.DB.QueryRow("SELECT COUNT(*) FROM "+from+" WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rows, err := app.DB.Query(`
+		SELECT a.id, a.date, a.repository, a.activity_type, a.count, COALESCE(a.url, ''), COALESCE(a.github_id, ''),
+		       a.number, a.title, a.state, a.message, a.private, a.stale
+		FROM `+from+`
+		WHERE `+where+`
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?
+	`, append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	results := []GitHubActivity{}
+	for rows.Next() {
+		var activity GitHubActivity
+		var dateStr string
+		err := rows.Scan(&activity.ID, &dateStr, &activity.Repository, &activity.ActivityType, &activity.Count,
+			&activity.URL, &activity.GitHubID, &activity.Number, &activity.Title, &activity.State, &activity.Message, &activity.Private, &activity.Stale)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		activity.Date = app.parseActivityDate(dateStr)
+		results = append(results, activity)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	_, end, pagination := paginate(total, page, limit)
+	setPageLinks(w, r, page, end < total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":      q,
+		"data":       results,
+		"pagination": pagination,
+	})
+}
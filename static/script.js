@@ -1,3 +1,17 @@
+// responseError builds an Error from a failed API response, using the message
+// of the JSON error body ({"error": "...", "code": 500}) when there is one
+async function responseError(response) {
+    try {
+        const body = await response.json();
+        if (body && body.error) {
+            return new Error(`${body.error} (status ${body.code || response.status})`);
+        }
+    } catch (e) {
+        // Not a JSON body; fall back to the status
+    }
+    return new Error(`HTTP error! status: ${response.status}`);
+}
+
 class RecentRepos {
     constructor() {
         this.activities = [];
@@ -7,6 +21,9 @@ class RecentRepos {
         this.currentCommitsPage = 1;
         this.commitsPerPage = 100;
         this.commitsPagination = null;
+        // Activity type the activity list is filtered to, set by the server
+        const defaultView = document.querySelector('meta[name="default-view"]');
+        this.defaultView = defaultView ? defaultView.content : 'all';
         this.init();
     }
 
@@ -81,7 +98,7 @@ class RecentRepos {
         try {
             const response = await fetch('/api/blog');
             if (!response.ok) {
-                throw new Error(`HTTP error! status: ${response.status}`);
+                throw await responseError(response);
             }
             this.blogData = await response.json();
             this.renderBlog();
@@ -112,8 +129,9 @@ class RecentRepos {
                                 <div class="activity-list-item">
                                     <span class="activity-date">${this.formatDate(pr.date)}</span>
                                     ${pr.url ? `<a href="${pr.url}" class="activity-link" target="_blank">
-                                        ${pr.count > 1 ? `${pr.count} pull requests` : 'Pull request'}
-                                    </a>` : `<span>${pr.count > 1 ? `${pr.count} pull requests` : 'Pull request'}</span>`}
+                                        ${this.describeItem(pr, 'pull request')}
+                                    </a>` : `<span>${this.describeItem(pr, 'pull request')}</span>`}
+                                    ${pr.state ? `<span class="item-state ${pr.state}">${pr.state}</span>` : ''}
                                 </div>
                             `).join('')}
                         </div>
@@ -128,8 +146,26 @@ class RecentRepos {
                                 <div class="activity-list-item">
                                     <span class="activity-date">${this.formatDate(issue.date)}</span>
                                     ${issue.url ? `<a href="${issue.url}" class="activity-link" target="_blank">
-                                        ${issue.count > 1 ? `${issue.count} issues` : 'Issue'}
-                                    </a>` : `<span>${issue.count > 1 ? `${issue.count} issues` : 'Issue'}</span>`}
+                                        ${this.describeItem(issue, 'issue')}
+                                    </a>` : `<span>${this.describeItem(issue, 'issue')}</span>`}
+                                    ${issue.state ? `<span class="item-state ${issue.state}">${issue.state}</span>` : ''}
+                                </div>
+                            `).join('')}
+                        </div>
+                    </div>
+                ` : ''}
+                
+                ${entry.reviews && entry.reviews.length > 0 ? `
+                    <div class="activity-section">
+                        <h4 class="activity-section-title">👀 Reviews</h4>
+                        <div class="activity-list">
+                            ${entry.reviews.map(review => `
+                                <div class="activity-list-item">
+                                    <span class="activity-date">${this.formatDate(review.date)}</span>
+                                    ${review.url ? `<a href="${review.url}" class="activity-link" target="_blank">
+                                        ${this.describeReview(review)}
+                                    </a>` : `<span>${this.describeReview(review)}</span>`}
+                                    ${review.state ? `<span class="item-state ${review.state}">${review.state.replace('_', ' ')}</span>` : ''}
                                 </div>
                             `).join('')}
                         </div>
@@ -157,6 +193,23 @@ class RecentRepos {
         blogTimeline.innerHTML = content;
     }
 
+    describeReview(review) {
+        if (review.number) {
+            return `Reviewed PR #${review.number}${review.title ? ` ${this.escapeHtml(review.title)}` : ''}`;
+        }
+        return 'Review';
+    }
+
+    describeItem(item, noun) {
+        if (item.number) {
+            return `#${item.number} ${this.escapeHtml(item.title)}`;
+        }
+        if (item.count > 1) {
+            return `${item.count} ${noun}s`;
+        }
+        return noun.charAt(0).toUpperCase() + noun.slice(1);
+    }
+
     async loadCommits(page = 1) {
         const commitsTimeline = document.getElementById('commits-timeline');
         if (page === 1) {
@@ -165,7 +218,7 @@ class RecentRepos {
         try {
             const response = await fetch(`/api/commits?page=${page}&limit=${this.commitsPerPage}`);
             if (!response.ok) {
-                throw new Error(`HTTP error! status: ${response.status}`);
+                throw await responseError(response);
             }
             const result = await response.json();
             this.commitsData = result.data || [];
@@ -186,13 +239,19 @@ class RecentRepos {
 
         let content = this.commitsData.map(repoGroup => `
             <div class="activity-item">
-                <a href="${repoGroup.commits[0].url}" class="repository-name" target="_blank">${repoGroup.repository}</a>
+                <a href="${repoGroup.url}" class="repository-name" target="_blank">${repoGroup.repository}</a>
                 <div class="commits-list">
-                    ${repoGroup.commits.map(commit => `
+                    ${repoGroup.buckets.map(bucket => `
                         <div class="commit-entry">
-                            <span class="activity-date">${this.formatDate(commit.date)}</span>
-                            <span>${commit.count} commit${commit.count > 1 ? 's' : ''}</span>
+                            <span class="activity-date">${this.formatDate(bucket.date)}</span>
+                            <span>${bucket.count} commit${bucket.count > 1 ? 's' : ''}</span>
                         </div>
+                        ${(bucket.commits || []).map(commit => `
+                            <div class="commit-detail">
+                                <a href="${commit.url}" class="commit-sha" target="_blank">${commit.sha.substring(0, 7)}</a>
+                                <span class="commit-message">${this.escapeHtml(commit.message.split('\n')[0])}</span>
+                            </div>
+                        `).join('')}
                     `).join('')}
                 </div>
             </div>
@@ -214,7 +273,7 @@ class RecentRepos {
         try {
             const response = await fetch('/api/projects');
             if (!response.ok) {
-                throw new Error(`HTTP error! status: ${response.status}`);
+                throw await responseError(response);
             }
             this.projectsData = await response.json();
             this.renderProjects();
@@ -267,6 +326,12 @@ class RecentRepos {
         projectsTimeline.innerHTML = content;
     }
 
+    escapeHtml(text) {
+        const div = document.createElement('div');
+        div.textContent = text || '';
+        return div.innerHTML;
+    }
+
     truncateText(text, maxLength) {
         if (!text) return '';
         if (text.length <= maxLength) return text;
@@ -299,13 +364,17 @@ class RecentRepos {
     async loadActivity() {
         try {
             this.showLoading(true);
-            const response = await fetch('/api/activity');
+            const url = this.defaultView === 'all'
+                ? '/api/activity'
+                : `/api/activity?type=${encodeURIComponent(this.defaultView)}`;
+            const response = await fetch(url);
             
             if (!response.ok) {
-                throw new Error(`HTTP error! status: ${response.status}`);
+                throw await responseError(response);
             }
             
-            this.activities = await response.json();
+            const result = await response.json();
+            this.activities = result.data;
             this.renderActivity();
         } catch (error) {
             this.showError('Failed to load activity: ' + error.message);
@@ -323,7 +392,7 @@ class RecentRepos {
             const response = await fetch('/api/refresh', { method: 'POST' });
             
             if (!response.ok) {
-                throw new Error(`HTTP error! status: ${response.status}`);
+                throw await responseError(response);
             }
             
             // Reload all views in parallel
@@ -349,10 +418,13 @@ class RecentRepos {
         }
 
         timeline.innerHTML = this.activities.map(activity => `
-            <div class="activity-item">
+            <div class="activity-item${activity.private ? ' private' : ''}">
                 <div class="activity-header">
                     <span class="activity-date">${this.formatDate(activity.date)}</span>
-                    <span class="activity-type ${activity.activity_type}">${activity.activity_type}</span>
+                    <span>
+                        ${activity.private ? '<span class="private-badge">private</span>' : ''}
+                        <span class="activity-type ${activity.activity_type}">${activity.activity_type}</span>
+                    </span>
                 </div>
                 <a href="${activity.url}" class="repository-name" target="_blank">
                     ${activity.repository}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ActivitySummary holds aggregate statistics over the history window
+type ActivitySummary struct {
+	From              string `json:"from"`
+	To                string `json:"to"`
+	TotalCommits      int    `json:"total_commits"`
+	TotalActivities   int    `json:"total_activities"`
+	ActiveRepos       int    `json:"active_repos"`
+	LongestStreak     int    `json:"longest_streak"`
+	CurrentStreak     int    `json:"current_streak"`
+	LongestStreakEnd  string `json:"longest_streak_end,omitempty"`
+	CurrentStreakFrom string `json:"current_streak_from,omitempty"`
+}
+
+// Handler for /api/summary: returns activity totals and commit streaks for the history window
+func (app *App) getSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	today := app.GitHubService.Today()
+	since := app.GitHubService.HistoryStart().Format("2006-01-02")
+
+	summary := ActivitySummary{
+		From: since,
+		To:   today.Format("2006-01-02"),
+	}
+
+	err := app.DB.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN activity_type = 'commit' THEN count ELSE 0 END), 0),
+		       COALESCE(SUM(count), 0),
+		       COUNT(DISTINCT repository)
+		FROM github_activity
+		WHERE date >= ?
+	`, since).Scan(&summary.TotalCommits, &summary.TotalActivities, &summary.ActiveRepos)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Distinct local days with at least one commit, oldest first
+	rows, err := app.DB.Query(`
+		SELECT date
+		FROM github_activity
+		WHERE activity_type = 'commit' AND date >= ?
+		ORDER BY date
+	`, since)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var dateStr string
+		if err := rows.Scan(&dateStr); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		day := bucketStart(app.parseActivityDate(dateStr), "day")
+		if n := len(days); n == 0 || !days[n-1].Equal(day) {
+			days = append(days, day)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	longest, longestEnd, current, currentFrom := commitStreaks(days, today)
+	summary.LongestStreak = longest
+	summary.CurrentStreak = current
+	if longest > 0 {
+		summary.LongestStreakEnd = longestEnd.Format("2006-01-02")
+	}
+	if current > 0 {
+		summary.CurrentStreakFrom = currentFrom.Format("2006-01-02")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// commitStreaks finds the longest run of consecutive commit days (the most
+// recent one on ties) and the run ending today. days must be sorted and
+// distinct. A current streak whose last day is yesterday still counts, since
+// today may not have a commit yet.
+func commitStreaks(days []time.Time, today time.Time) (longest int, longestEnd time.Time, current int, currentFrom time.Time) {
+	run := 0
+	var runStart time.Time
+	for i, day := range days {
+		if i > 0 && day.Equal(days[i-1].AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+			runStart = day
+		}
+		if run >= longest {
+			longest = run
+			longestEnd = day
+		}
+	}
+
+	if len(days) > 0 {
+		last := days[len(days)-1]
+		if last.Equal(today) || last.Equal(today.AddDate(0, 0, -1)) {
+			current = run
+			currentFrom = runStart
+		}
+	}
+
+	return longest, longestEnd, current, currentFrom
+}
+
+// parseYear reads the optional year query parameter, defaulting to the
+// current year
+func (app *App) parseYear(r *http.Request) (int, error) {
+	yearStr := r.URL.Query().Get("year")
+	if yearStr == "" {
+		return app.GitHubService.Today().Year(), nil
+	}
+	year, err := strconv.Atoi(yearStr)
+	if err != nil || year < 1970 || year > 9999 {
+		return 0, fmt.Errorf("invalid year: %s", yearStr)
+	}
+	return year, nil
+}
+
+// dailyTotals sums the activity counts of every day in [start, end) that had
+// any, keyed by YYYY-MM-DD in the configured time zone
+func (app *App) dailyTotals(start, end time.Time) (map[string]int, error) {
+	rows, err := app.DB.Query(`
+		SELECT date, count
+		FROM github_activity
+		WHERE date >= ? AND date < ?
+	`, formatActivityDate(start), formatActivityDate(end))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int)
+	for rows.Next() {
+		var date string
+		var count int
+		if err := rows.Scan(&date, &count); err != nil {
+			return nil, err
+		}
+		totals[app.parseActivityDate(date).Format("2006-01-02")] += count
+	}
+	return totals, rows.Err()
+}
+
+// Handler for /api/calendar: returns the total activity count for every day of
+// a year (default: the current year), including zeros, for a heatmap
+func (app *App) getCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := app.parseYear(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, app.GitHubService.location())
+	end := start.AddDate(1, 0, 0)
+
+	totals, err := app.dailyTotals(start, end)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	days := make(map[string]int)
+	total := 0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		days[date] = totals[date]
+		total += totals[date]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"year":  year,
+		"total": total,
+		"days":  days,
+	})
+}
+
+// ContributionDay is one cell of the contribution graph. Level buckets Count
+// relative to the busiest day of the year: 0 for none, then 1 to 4 by quarters.
+type ContributionDay struct {
+	Date    string `json:"date"`
+	Weekday int    `json:"weekday"`
+	Count   int    `json:"count"`
+	Level   int    `json:"level"`
+}
+
+// ContributionWeek is one column of the contribution graph, Sunday first. The
+// first and last weeks of a year may be partial.
+type ContributionWeek struct {
+	Days []ContributionDay `json:"days"`
+}
+
+// contributionLevel buckets count into the 0-4 intensity levels of the
+// contribution graph, relative to the busiest day's count
+func contributionLevel(count, busiest int) int {
+	if count <= 0 || busiest <= 0 {
+		return 0
+	}
+	return min((4*count+busiest-1)/busiest, 4)
+}
+
+// Handler for /api/contributions: returns the daily activity totals of a year
+// (default: the current year) shaped like GitHub's contribution graph, as
+// weeks starting on Sunday with an intensity level per day, along with the
+// year's total and busiest day count for a legend
+func (app *App) getContributionsHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := app.parseYear(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, app.GitHubService.location())
+	end := start.AddDate(1, 0, 0)
+
+	totals, err := app.dailyTotals(start, end)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	total, busiest := 0, 0
+	for _, count := range totals {
+		total += count
+		busiest = max(busiest, count)
+	}
+
+	weeks := []ContributionWeek{}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if len(weeks) == 0 || day.Weekday() == time.Sunday {
+			weeks = append(weeks, ContributionWeek{})
+		}
+		date := day.Format("2006-01-02")
+		week := &weeks[len(weeks)-1]
+		week.Days = append(week.Days, ContributionDay{
+			Date:    date,
+			Weekday: int(day.Weekday()),
+			Count:   totals[date],
+			Level:   contributionLevel(totals[date], busiest),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"year":  year,
+		"total": total,
+		"max":   busiest,
+		"weeks": weeks,
+	})
+}
+
+// maxTimelineDays caps how far back /api/timeline reaches
+const maxTimelineDays = 366
+
+// TimelineDay is the activity of one day summed across repositories
+type TimelineDay struct {
+	Date         string `json:"date"`
+	Commits      int    `json:"commits"`
+	PullRequests int    `json:"pull_requests"`
+	Issues       int    `json:"issues"`
+}
+
+// Handler for /api/timeline: returns commit, pull request and issue totals for
+// each of the last days days (default 90, at most maxTimelineDays) up to today,
+// oldest first, with days without activity set to 0
+func (app *App) getTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	days := 90
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		n, err := strconv.Atoi(daysStr)
+		if err != nil || n < 1 || n > maxTimelineDays {
+			writeJSONError(w, http.StatusBadRequest, "invalid days: "+daysStr+", expected 1 to "+strconv.Itoa(maxTimelineDays))
+			return
+		}
+		days = n
+	}
+
+	today := app.GitHubService.Today()
+	start := today.AddDate(0, 0, -(days - 1))
+
+	rows, err := app.DB.Query(`
+		SELECT date, activity_type, count
+		FROM github_activity
+		WHERE date >= ? AND activity_type IN ('commit', 'pull_request', 'issue')
+	`, formatActivityDate(start))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	// Sum each local day's activity by type
+	totals := make(map[string]TimelineDay)
+	for rows.Next() {
+		var dateStr, activityType string
+		var count int
+		if err := rows.Scan(&dateStr, &activityType, &count); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		date := app.parseActivityDate(dateStr).Format("2006-01-02")
+		day := totals[date]
+		day.Date = date
+		switch activityType {
+		case "commit":
+			day.Commits += count
+		case "pull_request":
+			day.PullRequests += count
+		case "issue":
+			day.Issues += count
+		}
+		totals[date] = day
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	timeline := make([]TimelineDay, 0, days)
+	for day := start; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		entry, ok := totals[date]
+		if !ok {
+			entry = TimelineDay{Date: date}
+		}
+		timeline = append(timeline, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+// LanguageCount is the total activity in repositories with a given primary
+// language
+type LanguageCount struct {
+	Language string `json:"language"`
+	Count    int    `json:"count"`
+	Repos    int    `json:"repos"`
+}
+
+// Handler for /api/languages: returns activity totals over the history window
+// grouped by each repository's primary language, busiest first. Activity in
+// repositories without a known language is reported as "Unknown".
+func (app *App) getLanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	since := app.GitHubService.HistoryStart().Format("2006-01-02")
+
+	rows, err := app.DB.Query(`
+		SELECT COALESCE(NULLIF(r.language, ''), 'Unknown') as language,
+		       SUM(a.count) as total,
+		       COUNT(DISTINCT a.repository)
+		FROM github_activity a
+		LEFT JOIN repositories r ON r.repository = a.repository
+		WHERE a.date >= ?
+		GROUP BY language
+		ORDER BY total DESC, language
+	`, since)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	languages := []LanguageCount{}
+	for rows.Next() {
+		var language LanguageCount
+		if err := rows.Scan(&language.Language, &language.Count, &language.Repos); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(languages)
+}
+
+// LanguageMonth is the number of commits made in a month, broken down by the
+// primary language of the repository they were made in
+type LanguageMonth struct {
+	Month     string         `json:"month"`
+	Total     int            `json:"total"`
+	Languages map[string]int `json:"languages"`
+}
+
+// Handler for /api/stats/languages-over-time: returns commit counts per
+// repository language for every month of the history window, oldest first,
+// including months without commits. Commits in repositories without a known
+// language are reported as "Unknown".
+func (app *App) getLanguagesOverTimeHandler(w http.ResponseWriter, r *http.Request) {
+	start := bucketStart(app.GitHubService.HistoryStart().In(app.GitHubService.location()), "month")
+
+	rows, err := app.DB.Query(`
+		SELECT a.date,
+		       COALESCE(NULLIF(r.language, ''), 'Unknown') as language,
+		       a.count
+		FROM github_activity a
+		LEFT JOIN repositories r ON r.repository = a.repository
+		WHERE a.activity_type = 'commit' AND a.date >= ?
+	`, formatActivityDate(start))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	months := make(map[string]*LanguageMonth)
+	var timeline []*LanguageMonth
+	for month := start; !month.After(time.Now()); month = month.AddDate(0, 1, 0) {
+		entry := &LanguageMonth{Month: month.Format("2006-01"), Languages: map[string]int{}}
+		months[entry.Month] = entry
+		timeline = append(timeline, entry)
+	}
+
+	for rows.Next() {
+		var dateStr, language string
+		var count int
+		if err := rows.Scan(&dateStr, &language, &count); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		// Commits dated in the future (clock skew) fall outside the months listed
+		month := app.parseActivityDate(dateStr).Format("2006-01")
+		if entry, ok := months[month]; ok {
+			entry.Languages[language] += count
+			entry.Total += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyTotalsGroupsByLocalDay(t *testing.T) {
+	app := newTestApp(t)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	app.GitHubService.Location = loc
+
+	// 01:00 UTC on March 2nd is still March 1st in New York, whatever the
+	// activity type or the offset it was reported with
+	app.store(t, UserActivity{Activities: []GitHubActivity{
+		{Date: time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC), Repository: "octo/app", ActivityType: "pull_request", Count: 1, GitHubID: "pr-1"},
+		{Date: time.Date(2024, 3, 1, 20, 30, 0, 0, loc), Repository: "octo/app", ActivityType: "commit", Count: 1, GitHubID: "aaa"},
+		{Date: time.Date(2024, 3, 2, 15, 0, 0, 0, time.UTC), Repository: "octo/app", ActivityType: "issue", Count: 1, GitHubID: "issue-2"},
+	}})
+
+	var offsets int
+	if err := app.DB.QueryRow("SELECT COUNT(*) FROM github_activity WHERE date NOT LIKE '%Z'").Scan(&offsets); err != nil {
+		t.Fatal(err)
+	}
+	if offsets != 0 {
+		t.Errorf("%d activity dates were stored with a non-UTC offset", offsets)
+	}
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, loc)
+	totals, err := app.dailyTotals(start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals["2024-03-01"] != 2 || totals["2024-03-02"] != 1 {
+		t.Errorf("got totals %v, want 2 on 2024-03-01 and 1 on 2024-03-02", totals)
+	}
+}